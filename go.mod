@@ -3,16 +3,22 @@ module github.com/loveuer/esgo2dump
 go 1.21
 
 require (
+	github.com/dop251/goja v0.0.0-20240927123429-241b342198c2
 	github.com/elastic/go-elasticsearch/v6 v6.8.10
 	github.com/elastic/go-elasticsearch/v7 v7.17.10
 	github.com/fatih/color v1.17.0
 	github.com/go-resty/resty/v2 v2.16.5
 	github.com/jedib0t/go-pretty/v6 v6.6.4
+	github.com/klauspost/compress v1.17.11
 	github.com/samber/lo v1.39.0
 	github.com/spf13/cobra v1.8.1
+	golang.org/x/net v0.33.0
 )
 
 require (
+	github.com/dlclark/regexp2 v1.11.4 // indirect
+	github.com/go-sourcemap/sourcemap v2.1.3+incompatible // indirect
+	github.com/google/pprof v0.0.0-20230207041349-798e818bf904 // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
@@ -20,6 +26,6 @@ require (
 	github.com/rivo/uniseg v0.2.0 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
 	golang.org/x/exp v0.0.0-20220303212507-bbda1eaf7a17 // indirect
-	golang.org/x/net v0.33.0 // indirect
 	golang.org/x/sys v0.28.0 // indirect
+	golang.org/x/text v0.21.0 // indirect
 )
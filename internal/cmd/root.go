@@ -42,14 +42,56 @@ func initRoot(cmds ...*cobra.Command) *cobra.Command {
 	// rootCommand.Flags().IntVar(&opt.Cfg.Args.Timeout, "timeout", 30, "max timeout seconds per operation with limit")
 	rootCommand.Flags().StringVarP(&opt.Cfg.Args.Input, "input", "i", "", "*required: input file or es url (example :data.json / http://127.0.0.1:9200/my_index)")
 	rootCommand.Flags().StringVarP(&opt.Cfg.Args.Output, "output", "o", "output.json", "")
-	rootCommand.Flags().StringVarP(&opt.Cfg.Args.Type, "type", "t", "data", "data/mapping/setting")
-	rootCommand.Flags().StringVar(&opt.Cfg.Args.Field, "field", "", "query include field, use ',' to separate")
+	rootCommand.Flags().StringVarP(&opt.Cfg.Args.Type, "type", "t", "data", "data/mapping/setting/all (all: settings, mapping, aliases and data as one bundle)")
+	rootCommand.Flags().StringVar(&opt.Cfg.Args.Field, "field", "", "_source fields to keep, use ',' to separate, '*' as wildcard and '-' prefix to exclude, example: user.*,-user.password")
 	rootCommand.Flags().StringVar(&opt.Cfg.Args.Sort, "sort", "", "sort, <field>:<direction> format, for example: time:desc or name:asc, user ',' to separate")
 	rootCommand.Flags().StringVar(&opt.Cfg.Args.Query, "query", "", `query dsl, example: {"bool":{"must":[{"term":{"name":{"value":"some_name"}}}],"must_not":[{"range":{"age":{"gte":18,"lt":60}}}]}}`)
 	rootCommand.Flags().StringVar(&opt.Cfg.Args.QueryFile, "query_file", "", `query json file (will execute line by line)`)
 	rootCommand.Flags().IntVar(&opt.Cfg.Args.Limit, "limit", 100, "")
 	rootCommand.Flags().IntVar(&opt.Cfg.Args.Max, "max", 0, "max dump records")
 	rootCommand.Flags().IntVar(&opt.Cfg.Args.SplitLimit, "split-limit", 0, "split output file when limit > 0, output must be a directory")
+	rootCommand.Flags().IntVar(&opt.Cfg.Args.FromPart, "from-part", 0, "when input is a split output directory, start reading at part number N")
+	rootCommand.Flags().IntVar(&opt.Cfg.Args.Slices, "slices", 0, "read es input with N parallel sliced scrolls (split directory input: N concurrent parts) when N > 1")
+	rootCommand.Flags().StringVar(&opt.Cfg.Args.Paginate, "paginate", opt.PaginateScroll, "es input pagination: scroll/pit/search_after")
+	rootCommand.Flags().BoolVar(&opt.Cfg.Args.PreserveIndex, "preserve-index", false, "write docs into their original _index instead of the output uri index")
+	rootCommand.Flags().BoolVar(&opt.Cfg.Args.CreateIndex, "create-index", false, "create the output es index with the input settings and mapping before dumping data, fails when it exists")
+	rootCommand.Flags().BoolVar(&opt.Cfg.Args.StaticSettings, "static-settings", false, "es output: apply static settings (e.g. analysis, codec) by closing and reopening the output index, skipped otherwise")
+	rootCommand.Flags().StringArrayVar(&opt.Cfg.Args.IndexRename, "index-rename", nil, "rename es output indices and preserved doc _index with from_regex=>to_template (e.g. 'prod-(.*)=>staging-$1'), repeatable, first match wins")
+	rootCommand.Flags().StringVar(&opt.Cfg.Args.Transform, "transform", "", "javascript file defining transform(doc) run on every doc before it is written: edit doc._source/_id in place, return a new doc, or null to drop it")
+	rootCommand.Flags().StringVar(&opt.Cfg.Args.Mask, "mask", "", `json spec file masking _source fields before they are written, e.g. ./mask.json containing {"salt":"s","fields":{"user.email":"hash","name":"fake","phone":"redact","ssn":"null","birthday":{"strategy":"truncate_date","unit":"month"}}}`)
+	rootCommand.Flags().BoolVar(&opt.Cfg.Args.DryRun, "dry-run", false, "print the plan (docs, estimated size, output index state, mapping compatibility, settings) and exit without writing anything")
+	rootCommand.Flags().BoolVar(&opt.Cfg.Args.Verify, "verify", false, "compare input and output doc counts per query instead of dumping, run it with the arguments of a finished dump")
+	rootCommand.Flags().BoolVar(&opt.Cfg.Args.VerifyIDs, "verify-ids", false, "verify: also diff the _id sets of input and output, up to 1000000 _id per query")
+	rootCommand.Flags().IntVar(&opt.Cfg.Args.VerifySample, "verify-sample", 0, "verify: also compare the _source hash of the first n input docs of every query with the output, implies --verify-ids")
+	rootCommand.Flags().StringVar(&opt.Cfg.Args.VerifyReport, "verify-report", "verify.report.json", "verify: file listing the count mismatches and missing, extra or different docs")
+	rootCommand.Flags().StringVar(&opt.Cfg.Args.TLS.CACert, "ca-cert", "", "pem ca bundle trusted by es input and output requests on top of the system roots")
+	rootCommand.Flags().StringVar(&opt.Cfg.Args.TLS.ClientCert, "client-cert", "", "pem client certificate of es input and output requests (mutual tls), needs --client-key")
+	rootCommand.Flags().StringVar(&opt.Cfg.Args.TLS.ClientKey, "client-key", "", "pem private key of --client-cert")
+	rootCommand.Flags().BoolVar(&opt.Cfg.Args.TLS.Insecure, "insecure", false, "skip the server certificate verification of es input and output requests")
+	rootCommand.Flags().StringVar(&opt.Cfg.Args.InputTLS.CACert, "input-ca-cert", "", "pem ca bundle trusted by es input requests on top of the system roots, overrides --ca-cert")
+	rootCommand.Flags().StringVar(&opt.Cfg.Args.InputTLS.ClientCert, "input-client-cert", "", "pem client certificate of es input requests (mutual tls), needs --input-client-key, overrides --client-cert")
+	rootCommand.Flags().StringVar(&opt.Cfg.Args.InputTLS.ClientKey, "input-client-key", "", "pem private key of --input-client-cert")
+	rootCommand.Flags().BoolVar(&opt.Cfg.Args.InputTLS.Insecure, "input-insecure", false, "skip the server certificate verification of es input requests")
+	rootCommand.Flags().StringVar(&opt.Cfg.Args.OutputTLS.CACert, "output-ca-cert", "", "pem ca bundle trusted by es output requests on top of the system roots, overrides --ca-cert")
+	rootCommand.Flags().StringVar(&opt.Cfg.Args.OutputTLS.ClientCert, "output-client-cert", "", "pem client certificate of es output requests (mutual tls), needs --output-client-key, overrides --client-cert")
+	rootCommand.Flags().StringVar(&opt.Cfg.Args.OutputTLS.ClientKey, "output-client-key", "", "pem private key of --output-client-cert")
+	rootCommand.Flags().BoolVar(&opt.Cfg.Args.OutputTLS.Insecure, "output-insecure", false, "skip the server certificate verification of es output requests")
+	rootCommand.Flags().StringVar(&opt.Cfg.Args.InputAuth.APIKey, "input-api-key", "", "es input api key (base64 id:api_key), env ESGO2DUMP_INPUT_API_KEY")
+	rootCommand.Flags().StringVar(&opt.Cfg.Args.OutputAuth.APIKey, "output-api-key", "", "es output api key (base64 id:api_key), env ESGO2DUMP_OUTPUT_API_KEY")
+	rootCommand.Flags().StringVar(&opt.Cfg.Args.InputAuth.BearerToken, "input-bearer-token", "", "es input bearer token, env ESGO2DUMP_INPUT_BEARER_TOKEN")
+	rootCommand.Flags().StringVar(&opt.Cfg.Args.OutputAuth.BearerToken, "output-bearer-token", "", "es output bearer token, env ESGO2DUMP_OUTPUT_BEARER_TOKEN")
+	rootCommand.Flags().StringVar(&opt.Cfg.Args.CredentialsFile, "credentials-file", "", "netrc style file of es credentials by host (machine <host[:port]> login <user> password <pass> | api_key <key> | bearer <token>), env ESGO2DUMP_CREDENTIALS_FILE, used when neither flags, uri nor ESGO2DUMP_<INPUT|OUTPUT>_{USERNAME,PASSWORD,API_KEY,BEARER_TOKEN} env give any")
+	rootCommand.Flags().StringVar(&opt.Cfg.Args.InputESVersion, "input-es-version", "", "es input version (6/7/8 or x.y.z, opensearch), skips the version detection")
+	rootCommand.Flags().StringVar(&opt.Cfg.Args.OutputESVersion, "output-es-version", "", "es output version (6/7/8 or x.y.z, opensearch), skips the version detection")
+	rootCommand.Flags().StringVar(&opt.Cfg.Args.Proxy, "proxy", "", "proxy of es input and output requests (http://, https://, socks5://), replaces the HTTPS_PROXY/HTTP_PROXY env, NO_PROXY still applies, direct ignores the env")
+	rootCommand.Flags().StringVar(&opt.Cfg.Args.InputProxy, "input-proxy", "", "proxy of es input requests, overrides --proxy")
+	rootCommand.Flags().StringVar(&opt.Cfg.Args.OutputProxy, "output-proxy", "", "proxy of es output requests, overrides --proxy")
+	rootCommand.Flags().IntVar(&opt.Cfg.Args.BulkRetries, "bulk-retries", 5, "retries of the docs an es output failed to write on 429, 503 or es_rejected_execution, with jittered exponential backoff")
+	rootCommand.Flags().StringVar(&opt.Cfg.Args.DeadLetter, "dead-letter", "", "append the docs an es output rejects for good (e.g. mapping errors) with their _error to this ndjson file instead of failing the dump, it can be dumped again as --input")
+	rootCommand.Flags().StringVar(&opt.Cfg.Args.Checkpoint, "checkpoint", "", "save data dump progress into this file, removed when the dump completes")
+	rootCommand.Flags().BoolVar(&opt.Cfg.Args.Resume, "resume", false, "resume data dump from the --checkpoint file")
+	rootCommand.Flags().StringVar(&opt.Cfg.Args.Compress, "compress", "", "output file compression: none/gzip/zstd, default by extension (.gz/.zst), compressed input is detected")
+	rootCommand.Flags().IntVar(&opt.Cfg.Args.Workers, "workers", 1, "concurrent data writers, reads are pipelined ahead of them")
 
 	rootCommand.AddCommand(cmds...)
 
@@ -35,28 +35,131 @@ func preRun(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("invalid limit(> 0)")
 	}
 
+	if opt.Cfg.Args.Slices < 0 {
+		return fmt.Errorf("invalid slices(>= 0)")
+	}
+
+	if opt.Cfg.Args.FromPart < 0 {
+		return fmt.Errorf("invalid from-part(>= 0)")
+	}
+
+	if opt.Cfg.Args.Workers < 1 {
+		return fmt.Errorf("invalid workers(> 0)")
+	}
+
+	switch opt.Cfg.Args.Paginate {
+	case opt.PaginateScroll, opt.PaginatePIT, opt.PaginateSearchAfter:
+	default:
+		return fmt.Errorf("unknown paginate=%s", opt.Cfg.Args.Paginate)
+	}
+
+	switch opt.Cfg.Args.Compress {
+	case "", opt.CompressNone, opt.CompressGzip, opt.CompressZstd:
+	default:
+		return fmt.Errorf("unknown compress=%s", opt.Cfg.Args.Compress)
+	}
+
 	if opt.Cfg.Args.Query != "" && opt.Cfg.Args.QueryFile != "" {
 		return fmt.Errorf("cannot specify both query and query_file at the same time")
 	}
 
 	switch opt.Cfg.Args.Type {
-	case "data", "mapping", "setting":
+	case "data", "mapping", "setting", "all":
 	default:
 		return fmt.Errorf("unknown type=%s", opt.Cfg.Args.Type)
 	}
 
+	if opt.Cfg.Args.CreateIndex && opt.Cfg.Args.Type != "data" {
+		return fmt.Errorf("create-index only supports type=data, type=all always creates the index")
+	}
+
+	if _, err := tool.NewIndexRenamer(opt.Cfg.Args.IndexRename); err != nil {
+		return err
+	}
+
+	if opt.Cfg.Args.Transform != "" && opt.Cfg.Args.Type != "data" && opt.Cfg.Args.Type != "all" {
+		return fmt.Errorf("transform only supports type=data or type=all")
+	}
+
+	if opt.Cfg.Args.Mask != "" && opt.Cfg.Args.Type != "data" && opt.Cfg.Args.Type != "all" {
+		return fmt.Errorf("mask only supports type=data or type=all")
+	}
+
+	for _, version := range []string{opt.Cfg.Args.InputESVersion, opt.Cfg.Args.OutputESVersion} {
+		if _, err := core.ParseESVersion(version); version != "" && err != nil {
+			return err
+		}
+	}
+
+	// tls files and credentials of es sides are checked before anything is read
+	for _, side := range []struct {
+		ioType model.IOType
+		uri    string
+	}{{model.Input, opt.Cfg.Args.Input}, {model.Output, opt.Cfg.Args.Output}} {
+		target, err := tool.ParseURI(side.uri)
+		if err != nil || tool.ValidScheme(target.Scheme) != nil {
+			continue
+		}
+
+		if _, err = core.NewConn(side.ioType, target); err != nil {
+			return err
+		}
+	}
+
+	if opt.Cfg.Args.VerifySample < 0 {
+		return fmt.Errorf("invalid verify-sample(>= 0)")
+	}
+
+	if (opt.Cfg.Args.VerifyIDs || opt.Cfg.Args.VerifySample > 0) && !opt.Cfg.Args.Verify {
+		return fmt.Errorf("verify-ids and verify-sample need --verify")
+	}
+
+	if opt.Cfg.Args.Verify {
+		if opt.Cfg.Args.DryRun {
+			return fmt.Errorf("cannot specify both dry-run and verify at the same time")
+		}
+
+		if opt.Cfg.Args.Type != "data" && opt.Cfg.Args.Type != "all" {
+			return fmt.Errorf("verify only supports type=data or type=all")
+		}
+	}
+
+	if opt.Cfg.Args.BulkRetries < 0 {
+		return fmt.Errorf("invalid bulk-retries(>= 0)")
+	}
+
+	if opt.Cfg.Args.DeadLetter != "" {
+		if opt.Cfg.Args.Type != "data" && opt.Cfg.Args.Type != "all" {
+			return fmt.Errorf("dead-letter only supports type=data or type=all")
+		}
+
+		if opt.Cfg.Args.DeadLetter == opt.Cfg.Args.Input || opt.Cfg.Args.DeadLetter == opt.Cfg.Args.Output {
+			return fmt.Errorf("dead-letter must not be the input or output file")
+		}
+	}
+
+	if opt.Cfg.Args.Resume && opt.Cfg.Args.Checkpoint == "" {
+		return fmt.Errorf("resume needs a checkpoint file")
+	}
+
+	if opt.Cfg.Args.Checkpoint != "" && opt.Cfg.Args.Type != "data" {
+		return fmt.Errorf("checkpoint only supports type=data")
+	}
+
 	// validate split-limit
-	if opt.Cfg.Args.SplitLimit > 0 {
+	if opt.Cfg.Args.SplitLimit > 0 && opt.Cfg.Args.Type != "all" {
 		if opt.Cfg.Args.Type != "data" {
-			return fmt.Errorf("split-limit only supports type=data")
+			return fmt.Errorf("split-limit only supports type=data or type=all")
 		}
 		// check if output is a directory
 		info, err := os.Stat(opt.Cfg.Args.Output)
 		if err != nil {
 			if os.IsNotExist(err) {
-				// directory doesn't exist, try to create it
-				if err = os.MkdirAll(opt.Cfg.Args.Output, 0755); err != nil {
-					return fmt.Errorf("failed to create output directory: %w", err)
+				// directory doesn't exist, try to create it, a dry run or verify creates nothing
+				if !opt.Cfg.Args.DryRun && !opt.Cfg.Args.Verify {
+					if err = os.MkdirAll(opt.Cfg.Args.Output, 0755); err != nil {
+						return fmt.Errorf("failed to create output directory: %w", err)
+					}
 				}
 			} else {
 				return fmt.Errorf("failed to check output path: %w", err)
@@ -68,6 +171,10 @@ func preRun(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	if opt.Cfg.Args.Checkpoint != "" && core.MultiIndex(opt.Cfg.Args.Input, opt.Cfg.Args.Output) {
+		return fmt.Errorf("checkpoint does not support multi index dumps, dump the indices one by one")
+	}
+
 	return nil
 }
 
@@ -78,6 +185,22 @@ func run(cmd *cobra.Command, args []string) error {
 		output model.IO[map[string]any]
 	)
 
+	if opt.Cfg.Args.DryRun {
+		return core.RunPlan(cmd)
+	}
+
+	if opt.Cfg.Args.Verify {
+		return core.RunVerify(cmd)
+	}
+
+	if core.MultiIndex(opt.Cfg.Args.Input, opt.Cfg.Args.Output) {
+		return core.RunMulti(cmd)
+	}
+
+	if core.IsMultiIndex(opt.Cfg.Args.Input) {
+		log.Warn("input names several indices, they are merged into %s, use a directory or an es uri without index as output to dump them one by one", opt.Cfg.Args.Output)
+	}
+
 	if input, err = core.NewIO(cmd.Context(), opt.Cfg.Args.Input, model.Input); err != nil {
 		return err
 	}
@@ -86,13 +209,5 @@ func run(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	switch opt.Cfg.Args.Type {
-	case "data":
-		return core.RunData(cmd, input, output)
-	case "mapping":
-		return core.RunMapping(cmd, input, output)
-	case "setting":
-		return core.RunSetting(cmd, input, output)
-	}
-	return fmt.Errorf("unknown args: type = %s", opt.Cfg.Args.Type)
+	return core.Run(cmd, input, output)
 }
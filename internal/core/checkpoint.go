@@ -0,0 +1,268 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/loveuer/esgo2dump/internal/opt"
+	"github.com/loveuer/esgo2dump/internal/tool"
+	"github.com/loveuer/esgo2dump/pkg/log"
+	"github.com/loveuer/esgo2dump/pkg/model"
+)
+
+// checkpoint is the on-disk progress of a data dump
+type checkpoint struct {
+	// Input and Output are redacted, the file must not leak the credentials of the uris
+	Input  string `json:"input"`
+	Output string `json:"output"`
+	// QueryLine is the 1-based query (query_file line) being dumped
+	QueryLine int `json:"query_line"`
+	Total     int `json:"total"`
+	// Readers holds one position per reader (slice) of the current query
+	Readers []readerPosition `json:"readers"`
+	// Writer is the output position, e.g. file offset or split file index
+	Writer    map[string]any `json:"writer,omitempty"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+type readerPosition struct {
+	// Read counts the docs read and written by the reader, inputs without a resumable position skip as many on resume
+	Read     int            `json:"read"`
+	Position map[string]any `json:"position,omitempty"`
+}
+
+// checkpointer keeps the checkpoint of a run, a nil checkpointer disables checkpointing
+type checkpointer struct {
+	mu     sync.Mutex
+	path   string
+	state  checkpoint
+	resume *checkpoint
+	saved  time.Time
+}
+
+func newCheckpointer(path string, readers int) (*checkpointer, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	c := &checkpointer{
+		path: path,
+		state: checkpoint{
+			Input:   tool.RedactURI(opt.Cfg.Args.Input),
+			Output:  tool.RedactURI(opt.Cfg.Args.Output),
+			Readers: make([]readerPosition, readers),
+		},
+	}
+
+	if !opt.Cfg.Args.Resume {
+		return c, nil
+	}
+
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		log.Warn("Dump: checkpoint %s not found, start from the beginning", path)
+		c.resume = &checkpoint{}
+
+		return c, nil
+	}
+
+	resume := new(checkpoint)
+	decoder := json.NewDecoder(bytes.NewReader(bs))
+	decoder.UseNumber()
+	if err = decoder.Decode(resume); err != nil {
+		return nil, fmt.Errorf("decode checkpoint %s: %w", path, err)
+	}
+
+	if len(resume.Readers) != readers {
+		return nil, fmt.Errorf("checkpoint was taken with %d readers, but got %d (check --slices)", len(resume.Readers), readers)
+	}
+
+	// checkpoints of older versions hold the plain uris
+	resume.Input, resume.Output = tool.RedactURI(resume.Input), tool.RedactURI(resume.Output)
+	if resume.Input != c.state.Input || resume.Output != c.state.Output {
+		log.Warn("Dump: checkpoint was taken for input = %s, output = %s", resume.Input, resume.Output)
+	}
+
+	log.Info("Dump: resume from checkpoint, query = %d, total = %d", resume.QueryLine, resume.Total)
+
+	c.resume = resume
+	c.state.QueryLine = resume.QueryLine
+	c.state.Total = resume.Total
+	c.state.Writer = resume.Writer
+
+	return c, nil
+}
+
+// restore moves the output back to the checkpoint and books the docs already dumped
+func (c *checkpointer) restore(ctx context.Context, output model.IO[map[string]any], counter *progress) error {
+	if c == nil || c.resume == nil {
+		return nil
+	}
+
+	counter.total = c.resume.Total
+	counter.reserved = c.resume.Total
+
+	if resumer, ok := output.(model.Resumer); ok {
+		return resumer.Resume(ctx, c.resume.Writer)
+	}
+
+	return nil
+}
+
+// skip reports whether a query was fully dumped before the checkpoint
+func (c *checkpointer) skip(line int) bool {
+	return c != nil && c.resume != nil && line < c.resume.QueryLine
+}
+
+// begin marks the start of a query, it returns the reader positions to resume from for the checkpointed query
+func (c *checkpointer) begin(line int) []readerPosition {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.resume != nil && line == c.resume.QueryLine {
+		copy(c.state.Readers, c.resume.Readers)
+		return c.resume.Readers
+	}
+
+	c.state.QueryLine = line
+	c.state.Readers = make([]readerPosition, len(c.state.Readers))
+
+	return nil
+}
+
+// writeData writes a batch and records the positions of its reader and output under one lock,
+// so a saved checkpoint never counts docs whose reader position is not saved yet (and vice versa).
+// it returns the written and the dead lettered counts, dead lettered docs count as dumped
+func (c *checkpointer) writeData(ctx context.Context, b *batch, output model.IO[map[string]any], dl *deadLetter) (int, int, error) {
+	if c == nil {
+		return dl.writeData(ctx, output, b.items)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	wrote, dead, err := dl.writeData(ctx, output, b.items)
+	if err != nil || wrote+dead != len(b.items) {
+		return wrote, dead, err
+	}
+
+	c.state.Total += wrote + dead
+	c.state.Readers[b.slot] = readerPosition{Read: b.read, Position: b.position}
+	c.state.Writer = position(output)
+
+	if time.Since(c.saved) >= time.Duration(opt.CheckpointIntervalSeconds)*time.Second {
+		if err = c.saveLocked(); err != nil {
+			log.Warn("Dump: save checkpoint failed, err = %s", err.Error())
+		}
+	}
+
+	return wrote, dead, nil
+}
+
+// save persists the last recorded positions
+func (c *checkpointer) save() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.saveLocked(); err != nil {
+		log.Warn("Dump: save checkpoint failed, err = %s", err.Error())
+	}
+}
+
+func (c *checkpointer) saveLocked() error {
+	c.state.UpdatedAt = time.Now()
+
+	bs, err := json.MarshalIndent(c.state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// write aside and rename, an interrupted save must not destroy the previous checkpoint
+	tmp := c.path + ".tmp"
+	if err = os.WriteFile(tmp, bs, 0o600); err != nil {
+		return err
+	}
+
+	if err = os.Rename(tmp, c.path); err != nil {
+		return err
+	}
+
+	c.saved = c.state.UpdatedAt
+
+	return nil
+}
+
+// finish removes the checkpoint of a completed run
+func (c *checkpointer) finish() {
+	if c == nil {
+		return
+	}
+
+	if err := os.Remove(c.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Warn("Dump: remove checkpoint failed, err = %s", err.Error())
+	}
+}
+
+func position(io model.IO[map[string]any]) map[string]any {
+	if resumer, ok := io.(model.Resumer); ok {
+		return resumer.Position()
+	}
+
+	return nil
+}
+
+// resumeReader moves a reader to its checkpointed position,
+// readers that cannot resume (e.g. an expired scroll) re-read and drop the docs dumped before.
+// es readers cut their pages, so whole pages are read and the docs of the last one past the checkpoint are returned
+// to be dumped first, asking for a short page would lose the rest of it (e.g. after --limit changed)
+func resumeReader(ctx context.Context, input model.IO[map[string]any], query map[string]any, pos readerPosition) ([]map[string]any, error) {
+	if pos.Read == 0 {
+		return nil, nil
+	}
+
+	if resumer, ok := input.(model.Resumer); ok && pos.Position != nil {
+		err := resumer.Resume(ctx, pos.Position)
+		if err == nil {
+			return nil, nil
+		}
+
+		log.Warn("Dump: resume reader position failed, skip %d docs instead, err = %s", pos.Read, err.Error())
+	}
+
+	for skipped := 0; skipped < pos.Read; {
+		items, err := input.ReadData(ctx, opt.Cfg.Args.Limit, query, splitArg(opt.Cfg.Args.Field), splitArg(opt.Cfg.Args.Sort))
+		if err != nil {
+			return nil, err
+		}
+
+		if len(items) == 0 {
+			return nil, fmt.Errorf("resume reader: want skip %d docs, but input has %d", pos.Read, skipped)
+		}
+
+		if skipped+len(items) > pos.Read {
+			return items[pos.Read-skipped:], nil
+		}
+
+		skipped += len(items)
+	}
+
+	return nil, nil
+}
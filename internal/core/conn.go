@@ -0,0 +1,138 @@
+package core
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/loveuer/esgo2dump/internal/opt"
+	"github.com/loveuer/esgo2dump/internal/tool"
+	"github.com/loveuer/esgo2dump/pkg/model"
+)
+
+// EnvCredentialsFile is the --credentials-file when the flag is not set
+const EnvCredentialsFile = "ESGO2DUMP_CREDENTIALS_FILE"
+
+// NewConn is how the requests of the input or output reach the es at target: tls, credentials and proxy
+func NewConn(ioType model.IOType, target *url.URL) (*tool.Conn, error) {
+	config, err := tlsConfig(ioType)
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := credentials(ioType, target)
+	if err != nil {
+		return nil, fmt.Errorf("%s credentials: %w", ioType, err)
+	}
+
+	proxy := opt.Cfg.Args.InputProxy
+	if ioType == model.Output {
+		proxy = opt.Cfg.Args.OutputProxy
+	}
+
+	if proxy == "" {
+		proxy = opt.Cfg.Args.Proxy
+	}
+
+	conn := &tool.Conn{TLS: config, Auth: creds}
+	if proxy != "" {
+		if conn.Proxy, conn.Direct, err = tool.ParseProxy(proxy); err != nil {
+			return nil, fmt.Errorf("%s proxy: %w", ioType, err)
+		}
+	}
+
+	return conn, nil
+}
+
+// newHTTPClient sends the requests of conn made outside of the es clients, like the version probe
+func newHTTPClient(conn *tool.Conn) *resty.Client {
+	hc := resty.New().SetTransport(conn.Transport())
+	if authorization := conn.Credentials().Authorization(); authorization != "" {
+		hc.SetHeader("Authorization", authorization)
+	}
+
+	return hc
+}
+
+// tlsConfig is the tls of the es requests of the input or output, from --input-*/--output-* tls flags
+// falling back to the shared ones, client cert and key are taken as a pair
+func tlsConfig(ioType model.IOType) (*tls.Config, error) {
+	var (
+		shared = opt.Cfg.Args.TLS
+		side   = opt.Cfg.Args.InputTLS
+	)
+
+	if ioType == model.Output {
+		side = opt.Cfg.Args.OutputTLS
+	}
+
+	if side.CACert == "" {
+		side.CACert = shared.CACert
+	}
+
+	if side.ClientCert == "" && side.ClientKey == "" {
+		side.ClientCert, side.ClientKey = shared.ClientCert, shared.ClientKey
+	}
+
+	config, err := tool.NewTLSConfig(side.CACert, side.ClientCert, side.ClientKey, side.Insecure || shared.Insecure)
+	if err != nil {
+		return nil, fmt.Errorf("%s tls: %w", ioType, err)
+	}
+
+	return config, nil
+}
+
+// credentials of one side come from the first source having any:
+// --<side>-api-key and --<side>-bearer-token, the uri user:password or api_key parameter,
+// ESGO2DUMP_<SIDE>_{API_KEY,BEARER_TOKEN,USERNAME,PASSWORD} env vars, then the credentials file entry of the uri host
+func credentials(ioType model.IOType, target *url.URL) (tool.Credentials, error) {
+	auth := opt.Cfg.Args.InputAuth
+	if ioType == model.Output {
+		auth = opt.Cfg.Args.OutputAuth
+	}
+
+	if creds := (tool.Credentials{APIKey: auth.APIKey, Bearer: auth.BearerToken}); !creds.IsZero() {
+		return creds, nil
+	}
+
+	var creds tool.Credentials
+	if target.User != nil {
+		creds.Username = target.User.Username()
+		creds.Password, _ = target.User.Password()
+	}
+
+	if creds.APIKey = target.Query().Get("api_key"); !creds.IsZero() {
+		return creds, nil
+	}
+
+	env := func(name string) string {
+		return os.Getenv("ESGO2DUMP_" + strings.ToUpper(string(ioType)) + "_" + name)
+	}
+
+	creds = tool.Credentials{Username: env("USERNAME"), Password: env("PASSWORD"), APIKey: env("API_KEY"), Bearer: env("BEARER_TOKEN")}
+	if !creds.IsZero() {
+		return creds, nil
+	}
+
+	path := opt.Cfg.Args.CredentialsFile
+	if path == "" {
+		path = os.Getenv(EnvCredentialsFile)
+	}
+
+	if path == "" {
+		return creds, nil
+	}
+
+	file, err := tool.ReadCredentialsFile(path)
+	if err != nil {
+		return creds, err
+	}
+
+	// the first host of a multi host uri keys the entry
+	creds, _ = file.Lookup(strings.Split(target.Host, ",")[0])
+
+	return creds, nil
+}
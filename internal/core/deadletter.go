@@ -0,0 +1,101 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/loveuer/esgo2dump/pkg/log"
+	"github.com/loveuer/esgo2dump/pkg/model"
+)
+
+// deadLetter appends the docs an output rejects for good to the --dead-letter file instead of failing the dump.
+// every line is a dump doc {_id, _index, _routing, _source} with the rejection in _error, so the file reads back as --input
+type deadLetter struct {
+	mu   sync.Mutex
+	path string
+	// file is opened on the first rejection, a clean dump leaves no file behind
+	file *os.File
+}
+
+func newDeadLetter(path string) *deadLetter {
+	if path == "" {
+		return nil
+	}
+
+	return &deadLetter{path: path}
+}
+
+// writeData writes items to output, it returns the written and the dead lettered counts.
+// without a dead letter file a rejection fails like any other write error
+func (d *deadLetter) writeData(ctx context.Context, output model.IO[map[string]any], items []map[string]any) (int, int, error) {
+	var bulkErr *model.BulkError
+
+	wrote, err := output.WriteData(ctx, items)
+	if err == nil || !errors.As(err, &bulkErr) {
+		return wrote, 0, err
+	}
+
+	if d == nil {
+		return wrote, 0, fmt.Errorf("%w, set --dead-letter to keep them and go on", err)
+	}
+
+	if err = d.write(bulkErr.Failures); err != nil {
+		return wrote, 0, err
+	}
+
+	log.Warn("Dump: %d docs rejected, appended to dead letter %s, first: %s", len(bulkErr.Failures), d.path, bulkErr.Failures[0].Error())
+
+	return wrote, len(bulkErr.Failures), nil
+}
+
+func (d *deadLetter) write(failures []*model.BulkFailure) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.file == nil {
+		file, err := os.OpenFile(d.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("open dead letter file: %w", err)
+		}
+
+		d.file = file
+	}
+
+	encoder := json.NewEncoder(d.file)
+	for _, failure := range failures {
+		if err := encoder.Encode(deadLetterLine(failure)); err != nil {
+			return fmt.Errorf("write dead letter file: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (d *deadLetter) close() {
+	if d == nil || d.file == nil {
+		return
+	}
+
+	if err := d.file.Close(); err != nil {
+		log.Warn("Dump: close dead letter file failed, err = %s", err.Error())
+	}
+}
+
+// deadLetterLine is the rejected doc with its _error, a plain doc is wrapped as _source
+func deadLetterLine(failure *model.BulkFailure) map[string]any {
+	line := map[string]any{"_source": failure.Item}
+	if _, ok := failure.Item["_source"].(map[string]any); ok {
+		line = make(map[string]any, len(failure.Item)+1)
+		for k, v := range failure.Item {
+			line[k] = v
+		}
+	}
+
+	line["_error"] = map[string]any{"status": failure.Status, "type": failure.Type, "reason": failure.Reason}
+
+	return line
+}
@@ -0,0 +1,76 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/loveuer/esgo2dump/internal/opt"
+	"github.com/loveuer/esgo2dump/internal/xfile"
+	"github.com/loveuer/esgo2dump/pkg/model"
+	"github.com/spf13/cobra"
+)
+
+func TestRunData_DeadLetter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dead.json")
+
+	opt.Cfg.Args.Limit = 4
+	defer func() {
+		opt.Cfg.Args.Limit = 0
+		opt.Cfg.Args.DeadLetter = ""
+	}()
+
+	input := &memIO{}
+	for i := 0; i < 10; i++ {
+		input.docs = append(input.docs, map[string]any{"_id": fmt.Sprint(i), "_index": "src", "_source": map[string]any{"n": i}})
+	}
+
+	run := func() (*memIO, error) {
+		input.offset = 0
+		output := &memIO{reject: map[any]bool{"3": true, "7": true}}
+
+		cmd := &cobra.Command{}
+		cmd.SetContext(context.Background())
+
+		return output, RunData(cmd, input, output)
+	}
+
+	// without a dead letter file the first rejection fails the dump
+	if _, err := run(); err == nil || !strings.Contains(err.Error(), "--dead-letter") {
+		t.Fatalf("RunData() error = %v, want a rejection pointing to --dead-letter", err)
+	}
+
+	opt.Cfg.Args.DeadLetter = path
+	output, err := run()
+	if err != nil {
+		t.Fatalf("RunData() error = %v", err)
+	}
+
+	if len(output.written) != 8 {
+		t.Errorf("RunData() wrote %d docs, want 8", len(output.written))
+	}
+
+	// the dead letter file is an input again
+	dead, err := xfile.NewClient(path, model.Input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := dead.ReadData(context.Background(), 10, nil, nil, nil)
+	if err != nil || len(items) != 2 {
+		t.Fatalf("ReadData() of the dead letter = %v, %v", items, err)
+	}
+
+	for i, id := range []string{"3", "7"} {
+		doc := model.ToESSource(items[i])
+		if doc.DocId != id || doc.Index != "src" || doc.Content["n"] == nil {
+			t.Errorf("dead letter doc %d = %v, want _id %s with its _index and _source", i, items[i], id)
+		}
+
+		if reason := items[i]["_error"].(map[string]any); reason["type"] != "mapper_parsing_exception" || reason["status"] != float64(400) {
+			t.Errorf("dead letter doc %d _error = %v", i, reason)
+		}
+	}
+}
@@ -1,7 +1,6 @@
 package core
 
 import (
-	"net/url"
 	"strings"
 
 	"github.com/loveuer/esgo2dump/internal/tool"
@@ -10,7 +9,7 @@ import (
 // ExtractIndexName extracts index name from input URI
 // Returns empty string if URI is not an ES URL or index cannot be extracted
 func ExtractIndexName(inputURI string) string {
-	target, err := url.Parse(inputURI)
+	target, err := tool.ParseURI(inputURI)
 	if err != nil {
 		return ""
 	}
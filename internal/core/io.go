@@ -2,21 +2,28 @@ package core
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	elastic6 "github.com/elastic/go-elasticsearch/v6"
 	elastic7 "github.com/elastic/go-elasticsearch/v7"
-	"github.com/go-resty/resty/v2"
 	"github.com/loveuer/esgo2dump/internal/opt"
 	"github.com/loveuer/esgo2dump/internal/tool"
 	"github.com/loveuer/esgo2dump/internal/xfile"
 	"github.com/loveuer/esgo2dump/pkg/log"
 	"github.com/loveuer/esgo2dump/pkg/model"
+	"github.com/loveuer/esgo2dump/xes/es6"
 	"github.com/loveuer/esgo2dump/xes/es7"
+	"github.com/loveuer/esgo2dump/xes/es8"
 	"net/url"
+	"os"
 	"strings"
 )
 
 func NewIO(ctx context.Context, uri string, ioType model.IOType) (model.IO[map[string]any], error) {
+	// a whole index (--type=all) goes to or comes from a bundle when the uri is not es
+	if ExtractIndexName(uri) == "" && (opt.Cfg.Args.Type == "all" || ioType == model.Input && xfile.IsBundle(uri)) {
+		return xfile.NewBundle(uri, ExtractIndexName(opt.Cfg.Args.Input), ioType)
+	}
+
 	// Handle split mode for output
 	if ioType == model.Output && opt.Cfg.Args.SplitLimit > 0 {
 		// Split mode: output must be a directory
@@ -28,21 +35,19 @@ func NewIO(ctx context.Context, uri string, ioType model.IOType) (model.IO[map[s
 		return xfile.NewSplitClient(uri, indexName, opt.Cfg.Args.SplitLimit)
 	}
 
-	type Version struct {
-		Name    string
-		Version struct {
-			Number string `json:"number"`
-		} `json:"version"`
+	// a directory input is read back as split output
+	if ioType == model.Input {
+		if info, err := os.Stat(uri); err == nil && info.IsDir() {
+			return xfile.NewSplitReader(uri)
+		}
 	}
 
 	var (
 		err    error
 		target *url.URL
-		rr     *resty.Response
-		v      Version
 	)
 
-	if target, err = url.Parse(uri); err != nil {
+	if target, err = tool.ParseURI(uri); err != nil {
 		log.Debug("parse uri failed, type = %s, uri = %s, err = %s", ioType, uri, err.Error())
 		return xfile.NewClient(uri, ioType)
 	}
@@ -58,35 +63,57 @@ func NewIO(ctx context.Context, uri string, ioType model.IOType) (model.IO[map[s
 		return nil, fmt.Errorf("uri invalid without index(path)")
 	}
 
+	if ioType == model.Output {
+		renamer, err := tool.NewIndexRenamer(opt.Cfg.Args.IndexRename)
+		if err != nil {
+			return nil, err
+		}
+
+		if renamed := renamer.Rename(index); renamed != index {
+			log.Info("output index %s renamed to %s", index, renamed)
+			index = renamed
+		}
+	}
+
 	log.Debug("%s uri es index = %s", ioType, index)
 
-	versionURL := fmt.Sprintf("%s://%s", target.Scheme, strings.Split(target.Host, ",")[0])
-	log.Debug("%s version url = %s", ioType, versionURL)
-	if rr, err = opt.HttpClient.R().Get(versionURL); err != nil {
-		log.Debug("get uri es version failed, type = %s, uri = %s, version_url = %s, err = %s", ioType, uri, versionURL, err.Error())
+	conn, err := NewConn(ioType, target)
+	if err != nil {
+		return nil, err
 	}
 
-	if err = json.Unmarshal(rr.Body(), &v); err != nil {
-		log.Debug("decode uri es version failed, type = %s, uri = %s, version_url = %s, err = %s", ioType, uri, versionURL, err.Error())
+	mainVersion, err := esVersion(ctx, ioType, target, conn)
+	if err != nil {
 		return nil, err
 	}
 
-	log.Debug("%s uri es version = %s", ioType, v.Version.Number)
+	if ioType == model.Input && mainVersion != "7" && opt.Cfg.Args.Paginate != "" && opt.Cfg.Args.Paginate != opt.PaginateScroll {
+		log.Warn("paginate=%s is only supported by es7 input, fallback to scroll", opt.Cfg.Args.Paginate)
+	}
 
-	mainVersion := strings.Split(v.Version.Number, ".")[0]
 	switch mainVersion {
 	case "8":
+		var client *es8.Client
+		if client, err = es8.NewClient(ctx, uri, conn); err != nil {
+			return nil, err
+		}
+
+		return es8.NewStreamer(ctx, client, index)
 	case "7":
 		var client *elastic7.Client
-		if client, err = es7.NewClient(ctx, uri); err != nil {
+		if client, err = es7.NewClient(ctx, uri, conn); err != nil {
 			return nil, err
 		}
 
 		return es7.NewStreamer(ctx, client, index)
 	case "6":
-	default:
-		return nil, fmt.Errorf("es version not supported yet: %s", mainVersion)
+		var client *elastic6.Client
+		if client, err = es6.NewClient(ctx, target, conn); err != nil {
+			return nil, err
+		}
+
+		return es6.NewStreamer(ctx, client, index)
 	}
 
-	return nil, nil
+	return nil, fmt.Errorf("es version not supported yet: %s", mainVersion)
 }
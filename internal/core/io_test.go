@@ -2,6 +2,9 @@ package core
 
 import (
 	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
@@ -35,3 +38,174 @@ func TestNewIO_SplitOutput(t *testing.T) {
 		t.Fatalf("NewIO(split output) got err=%v io=%v", err, io)
 	}
 }
+
+func TestNewIO_SplitInput(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "my_index-1.json"), []byte("{}\n"), 0o644); err != nil {
+		t.Fatalf("write part: %v", err)
+	}
+
+	io, err := NewIO(context.Background(), tmpDir, model.Input)
+	if err != nil || io == nil {
+		t.Fatalf("NewIO(split input) got err=%v io=%v", err, io)
+	}
+
+	if _, ok := io.(model.Slicer[map[string]any]); !ok {
+		t.Errorf("NewIO(split input) = %T, want a model.Slicer", io)
+	}
+}
+
+func TestNewIO_OpenSearch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"name":"node","version":{"distribution":"opensearch","number":"2.11.0"}}`))
+	}))
+	defer server.Close()
+
+	io, err := NewIO(context.Background(), server.URL+"/my_index", model.Input)
+	if err != nil || io == nil {
+		t.Fatalf("NewIO(opensearch) got err=%v io=%v", err, io)
+	}
+}
+
+func TestNewIO_IndexRename(t *testing.T) {
+	opt.Cfg.Args.IndexRename = []string{"prod-(.*)=>staging-$1"}
+	defer func() { opt.Cfg.Args.IndexRename = nil }()
+
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"name":"node","version":{"distribution":"opensearch","number":"2.11.0"},"acknowledged":true}`))
+	}))
+	defer server.Close()
+
+	output, err := NewIO(context.Background(), server.URL+"/prod-orders", model.Output)
+	if err != nil {
+		t.Fatalf("NewIO(output) error = %v", err)
+	}
+
+	mapping := map[string]any{"prod-orders": map[string]any{"mappings": map[string]any{}}}
+	if err = output.WriteMapping(context.Background(), mapping); err != nil {
+		t.Fatalf("WriteMapping() error = %v", err)
+	}
+
+	if last := paths[len(paths)-1]; last != "PUT /staging-orders" {
+		t.Errorf("WriteMapping() requested %s, want PUT /staging-orders", last)
+	}
+}
+
+func TestNewIO_TLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"name":"node","version":{"distribution":"opensearch","number":"2.11.0"}}`))
+	}))
+	defer server.Close()
+
+	caPath := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		opt.Cfg.Args.TLS, opt.Cfg.Args.InputTLS, opt.Cfg.Args.OutputTLS = opt.TLS{}, opt.TLS{}, opt.TLS{}
+	}()
+
+	if _, err := NewIO(context.Background(), server.URL+"/my_index", model.Input); err == nil {
+		t.Fatalf("NewIO() of an untrusted server should fail")
+	}
+
+	// the ca of one side does not leak into the other
+	opt.Cfg.Args.InputTLS.CACert = caPath
+	if _, err := NewIO(context.Background(), server.URL+"/my_index", model.Input); err != nil {
+		t.Fatalf("NewIO(input) with --input-ca-cert error = %v", err)
+	}
+
+	if _, err := NewIO(context.Background(), server.URL+"/my_index", model.Output); err == nil {
+		t.Fatalf("NewIO(output) with --input-ca-cert only should fail")
+	}
+
+	opt.Cfg.Args.TLS.CACert = caPath
+	if _, err := NewIO(context.Background(), server.URL+"/my_index", model.Output); err != nil {
+		t.Fatalf("NewIO(output) with --ca-cert error = %v", err)
+	}
+}
+
+func TestNewIO_Credentials(t *testing.T) {
+	var auth []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = append(auth, r.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"name":"node","version":{"distribution":"opensearch","number":"2.11.0"}}`))
+	}))
+	defer server.Close()
+
+	credentials := filepath.Join(t.TempDir(), "credentials")
+	if err := os.WriteFile(credentials, []byte("machine "+server.Listener.Addr().String()+" login reader password r3ader\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { opt.Cfg.Args.InputAuth, opt.Cfg.Args.CredentialsFile = opt.Auth{}, "" }()
+
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T)
+		userURI bool
+		want    string
+	}{
+		{name: "credentials file", setup: func(t *testing.T) { opt.Cfg.Args.CredentialsFile = credentials }, want: "Basic cmVhZGVyOnIzYWRlcg=="},
+		{name: "env over file", setup: func(t *testing.T) { t.Setenv("ESGO2DUMP_INPUT_BEARER_TOKEN", "t0ken") }, want: "Bearer t0ken"},
+		{name: "uri over env", setup: func(t *testing.T) { t.Setenv("ESGO2DUMP_INPUT_BEARER_TOKEN", "t0ken") }, userURI: true, want: "Basic ZWxhc3RpYzpzM2NyZXQ="},
+		{name: "flag over uri", setup: func(t *testing.T) { opt.Cfg.Args.InputAuth.APIKey = "aWQ6a2V5" }, userURI: true, want: "ApiKey aWQ6a2V5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setup(t)
+			auth = nil
+
+			uri := server.URL + "/my_index"
+			if tt.userURI {
+				uri = "http://elastic:s3cret@" + server.Listener.Addr().String() + "/my_index"
+			}
+
+			if _, err := NewIO(context.Background(), uri, model.Input); err != nil {
+				t.Fatalf("NewIO() error = %v", err)
+			}
+
+			// the version probe and the client ping
+			if len(auth) != 2 || auth[0] != tt.want || auth[1] != tt.want {
+				t.Errorf("Authorization = %v, want %s", auth, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewIO_Proxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.Host)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"name":"node","version":{"distribution":"opensearch","number":"2.11.0"}}`))
+	}))
+	defer proxy.Close()
+
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+
+	// the input one overrides the shared one
+	opt.Cfg.Args.Proxy, opt.Cfg.Args.InputProxy = dead.URL, proxy.URL
+	defer func() { opt.Cfg.Args.Proxy, opt.Cfg.Args.InputProxy = "", "" }()
+
+	if _, err := NewIO(context.Background(), "http://es.internal:9200/my_index", model.Input); err != nil {
+		t.Fatalf("NewIO() through --input-proxy error = %v", err)
+	}
+
+	if len(proxied) != 2 || proxied[0] != "es.internal:9200" {
+		t.Errorf("proxy got %v, want the version probe and the ping of es.internal:9200", proxied)
+	}
+
+	if _, err := NewIO(context.Background(), "http://es.internal:9200/my_index", model.Output); err == nil {
+		t.Errorf("NewIO(output) through the dead --proxy should fail")
+	}
+}
@@ -0,0 +1,313 @@
+package core
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	maskHash         = "hash"
+	maskRedact       = "redact"
+	maskFake         = "fake"
+	maskNull         = "null"
+	maskTruncateDate = "truncate_date"
+
+	maskRedacted = "REDACTED"
+)
+
+// maskDateLayouts are the string dates truncate_date understands, a date keeps its layout
+var maskDateLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+// maskSpec is a --mask file: {"salt": "...", "fields": {"user.email": "hash", "birthday": {"strategy": "truncate_date", "unit": "month"}}}
+type maskSpec struct {
+	Salt   string               `json:"salt"`
+	Fields map[string]*maskRule `json:"fields"`
+}
+
+// maskRule is the strategy of one field, a plain string is the strategy name
+type maskRule struct {
+	Strategy string `json:"strategy"`
+	// Value replaces redacted values, Unit (year/month/day/hour) is where truncate_date cuts
+	Value any    `json:"value"`
+	Unit  string `json:"unit"`
+}
+
+func (r *maskRule) UnmarshalJSON(bs []byte) error {
+	if err := json.Unmarshal(bs, &r.Strategy); err == nil {
+		return nil
+	}
+
+	type rule maskRule
+
+	return json.Unmarshal(bs, (*rule)(r))
+}
+
+// masker anonymizes the _source fields of a --mask spec, every strategy but redact and null is keyed by the salt
+// so the same value masks the same way in every doc and index, and joins still line up
+type masker struct {
+	salt   []byte
+	fields map[string]*maskRule
+}
+
+// newMasker loads the spec at path, nil when path is empty
+func newMasker(path string) (*masker, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read mask spec: %w", err)
+	}
+
+	spec := new(maskSpec)
+	if err = json.Unmarshal(bs, spec); err != nil {
+		return nil, fmt.Errorf("decode mask spec: %w", err)
+	}
+
+	if len(spec.Fields) == 0 {
+		return nil, fmt.Errorf("mask spec %s has no fields", path)
+	}
+
+	for field, rule := range spec.Fields {
+		switch rule.Strategy {
+		case maskHash, maskFake, maskNull:
+		case maskRedact:
+			if rule.Value == nil {
+				rule.Value = maskRedacted
+			}
+		case maskTruncateDate:
+			switch rule.Unit {
+			case "":
+				rule.Unit = "day"
+			case "year", "month", "day", "hour":
+			default:
+				return nil, fmt.Errorf("mask %s: unknown truncate_date unit=%s", field, rule.Unit)
+			}
+		default:
+			return nil, fmt.Errorf("mask %s: unknown strategy=%s", field, rule.Strategy)
+		}
+	}
+
+	return &masker{salt: []byte(spec.Salt), fields: spec.Fields}, nil
+}
+
+// apply masks items in place
+func (m *masker) apply(items []map[string]any) error {
+	if m == nil {
+		return nil
+	}
+
+	for _, item := range items {
+		source, ok := item["_source"].(map[string]any)
+		if !ok {
+			source = item
+		}
+
+		for field, rule := range m.fields {
+			if err := m.maskPath(source, field, rule); err != nil {
+				return fmt.Errorf("mask %s of doc %v: %w", field, item["_id"], err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// maskPath masks the values at a dotted path, through arrays, a dotted key wins over nested objects
+func (m *masker) maskPath(value any, path string, rule *maskRule) error {
+	switch v := value.(type) {
+	case []any:
+		for _, item := range v {
+			if err := m.maskPath(item, path, rule); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		if item, ok := v[path]; ok {
+			masked, err := m.mask(item, rule)
+			if err != nil {
+				return err
+			}
+
+			v[path] = masked
+
+			return nil
+		}
+
+		if head, tail, found := strings.Cut(path, "."); found {
+			return m.maskPath(v[head], tail, rule)
+		}
+	}
+
+	return nil
+}
+
+// mask returns the masked value, objects and arrays are masked leaf by leaf unless they are nullified or redacted
+func (m *masker) mask(value any, rule *maskRule) (any, error) {
+	switch rule.Strategy {
+	case maskNull:
+		return nil, nil
+	case maskRedact:
+		return rule.Value, nil
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case []any:
+		for i := range v {
+			masked, err := m.mask(v[i], rule)
+			if err != nil {
+				return nil, err
+			}
+
+			v[i] = masked
+		}
+
+		return v, nil
+	case map[string]any:
+		for key := range v {
+			masked, err := m.mask(v[key], rule)
+			if err != nil {
+				return nil, err
+			}
+
+			v[key] = masked
+		}
+
+		return v, nil
+	}
+
+	switch rule.Strategy {
+	case maskHash:
+		return hex.EncodeToString(m.sum(value)), nil
+	case maskFake:
+		return m.fake(value)
+	case maskTruncateDate:
+		return truncateDate(value, rule.Unit)
+	}
+
+	return value, nil
+}
+
+// sum is the salted hmac of a scalar, strings are keyed by their text so "1" and 1 stay apart
+func (m *masker) sum(value any) []byte {
+	key, ok := value.(string)
+	if !ok {
+		bs, _ := json.Marshal(value)
+		key = string(bs)
+	}
+
+	mac := hmac.New(sha256.New, m.salt)
+	mac.Write([]byte(key))
+
+	return mac.Sum(nil)
+}
+
+// fake keeps the shape of a value: letters stay letters of the same case, digits stay digits, anything else is kept.
+// numbers keep their digit count and sign, bools are left alone
+func (m *masker) fake(value any) (any, error) {
+	switch v := value.(type) {
+	case string:
+		return m.fakeString(v, v), nil
+	case float64:
+		s := strconv.FormatFloat(v, 'f', -1, 64)
+		faked := m.fakeString(s, value)
+
+		// no leading zero, the number would lose a digit
+		if i := strings.IndexFunc(faked, unicode.IsDigit); i >= 0 && faked[i] == '0' && len(faked) > i+1 && faked[i+1] != '.' {
+			faked = faked[:i] + "1" + faked[i+1:]
+		}
+
+		return strconv.ParseFloat(faked, 64)
+	case int64:
+		// set by a --transform script
+		return m.fake(float64(v))
+	case bool:
+		return v, nil
+	}
+
+	return nil, fmt.Errorf("can not fake %T", value)
+}
+
+// fakeString swaps the letters and digits of s with ones drawn from the salted hmac of key
+func (m *masker) fakeString(s string, key any) string {
+	var (
+		sb      strings.Builder
+		stream  = m.sum(key)
+		counter uint32
+		next    = func() uint32 {
+			if len(stream) < 4 {
+				counter++
+				extra := make([]byte, 4)
+				binary.BigEndian.PutUint32(extra, counter)
+				stream = m.sum(fmt.Sprint(key, extra))
+			}
+
+			n := binary.BigEndian.Uint32(stream)
+			stream = stream[4:]
+
+			return n
+		}
+	)
+
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z':
+			sb.WriteRune('a' + rune(next()%26))
+		case r >= 'A' && r <= 'Z':
+			sb.WriteRune('A' + rune(next()%26))
+		case unicode.IsLetter(r):
+			sb.WriteRune('a' + rune(next()%26))
+		case r >= '0' && r <= '9':
+			sb.WriteRune('0' + rune(next()%10))
+		default:
+			sb.WriteRune(r)
+		}
+	}
+
+	return sb.String()
+}
+
+// truncateDate cuts a date to its unit, string dates keep their layout and numbers are epoch millis
+func truncateDate(value any, unit string) (any, error) {
+	cut := func(t time.Time) time.Time {
+		switch unit {
+		case "year":
+			return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, t.Location())
+		case "month":
+			return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+		case "hour":
+			return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+		}
+
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	}
+
+	switch v := value.(type) {
+	case string:
+		for _, layout := range maskDateLayouts {
+			if t, err := time.Parse(layout, v); err == nil {
+				return cut(t).Format(layout), nil
+			}
+		}
+
+		return nil, fmt.Errorf("unknown date format: %s", v)
+	case float64:
+		return float64(cut(time.UnixMilli(int64(v)).UTC()).UnixMilli()), nil
+	case int64:
+		return cut(time.UnixMilli(v).UTC()).UnixMilli(), nil
+	}
+
+	return nil, fmt.Errorf("can not truncate %T as a date", value)
+}
@@ -0,0 +1,124 @@
+package core
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func newTestMasker(t *testing.T, spec string) *masker {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "mask.json")
+	if err := os.WriteFile(path, []byte(spec), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := newMasker(path)
+	if err != nil {
+		t.Fatalf("newMasker() error = %v", err)
+	}
+
+	return m
+}
+
+func TestMasker_Apply(t *testing.T) {
+	m := newTestMasker(t, `{"salt": "pepper", "fields": {
+		"user.email": "hash",
+		"user.name": "fake",
+		"phones": "fake",
+		"note": {"strategy": "redact", "value": "[removed]"},
+		"ssn": "null",
+		"birthday": {"strategy": "truncate_date", "unit": "month"},
+		"seen_at": {"strategy": "truncate_date", "unit": "year"},
+		"tags.owner": "redact",
+		"missing.field": "hash"
+	}}`)
+
+	doc := func() map[string]any {
+		item := make(map[string]any)
+		_ = json.Unmarshal([]byte(`{"_id": "1", "_source": {
+			"user": {"email": "Jane@Example.com", "name": "Jane Doe"},
+			"phones": ["+1 555-0100", 5550101],
+			"note": "vip",
+			"ssn": "123-45-6789",
+			"birthday": "1990-07-15",
+			"seen_at": 1718454896000,
+			"tags": [{"owner": "a"}, {"owner": "b", "kind": "x"}]
+		}}`), &item)
+
+		return item
+	}
+
+	first, second := doc(), doc()
+	if err := m.apply([]map[string]any{first, second}); err != nil {
+		t.Fatalf("apply() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("apply() is not deterministic: %v != %v", first, second)
+	}
+
+	source := first["_source"].(map[string]any)
+	user := source["user"].(map[string]any)
+
+	if !regexp.MustCompile(`^[0-9a-f]{64}$`).MatchString(user["email"].(string)) {
+		t.Errorf("hash email = %v", user["email"])
+	}
+
+	if name := user["name"].(string); name == "Jane Doe" || !regexp.MustCompile(`^[A-Z][a-z]{3} [A-Z][a-z]{2}$`).MatchString(name) {
+		t.Errorf("fake name = %s, want the shape of Jane Doe", name)
+	}
+
+	phones := source["phones"].([]any)
+	if phone := phones[0].(string); phone == "+1 555-0100" || !regexp.MustCompile(`^\+\d \d{3}-\d{4}$`).MatchString(phone) {
+		t.Errorf("fake phone = %s", phone)
+	}
+
+	if n := phones[1].(float64); n == 5550101 || n < 1000000 || n > 9999999 {
+		t.Errorf("fake number = %v, want 7 digits", n)
+	}
+
+	want := map[string]any{
+		"note":     "[removed]",
+		"ssn":      nil,
+		"birthday": "1990-07-01",
+		"seen_at":  float64(1704067200000),
+		"tags":     []any{map[string]any{"owner": "REDACTED"}, map[string]any{"owner": "REDACTED", "kind": "x"}},
+	}
+	for key, value := range want {
+		if !reflect.DeepEqual(source[key], value) {
+			t.Errorf("%s = %v, want %v", key, source[key], value)
+		}
+	}
+
+	// the same value hashes the same way in another index, so joins still line up
+	other := map[string]any{"user": map[string]any{"email": "Jane@Example.com"}}
+	if err := m.apply([]map[string]any{other}); err != nil {
+		t.Fatalf("apply() error = %v", err)
+	}
+
+	if other["user"].(map[string]any)["email"] != user["email"] {
+		t.Errorf("hash of a plain doc = %v, want %v", other["user"], user["email"])
+	}
+}
+
+func TestMasker_InvalidSpec(t *testing.T) {
+	for _, spec := range []string{
+		`{"fields": {}}`,
+		`{"fields": {"a": "scramble"}}`,
+		`{"fields": {"a": {"strategy": "truncate_date", "unit": "week"}}}`,
+	} {
+		path := filepath.Join(t.TempDir(), "mask.json")
+		if err := os.WriteFile(path, []byte(spec), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := newMasker(path); err == nil {
+			t.Errorf("newMasker(%s) should fail", spec)
+		}
+	}
+}
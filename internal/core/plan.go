@@ -0,0 +1,335 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/loveuer/esgo2dump/internal/opt"
+	"github.com/loveuer/esgo2dump/internal/tool"
+	"github.com/loveuer/esgo2dump/internal/xfile"
+	"github.com/loveuer/esgo2dump/pkg/log"
+	"github.com/loveuer/esgo2dump/pkg/model"
+	"github.com/spf13/cobra"
+)
+
+// plan is what a run would do for one input and output, --dry-run prints it instead of running
+type plan struct {
+	Input         string
+	Output        string
+	Type          string
+	Docs          string
+	EstimatedSize string
+	Target        string
+	Mapping       string
+	Settings      string
+}
+
+// RunPlan prints the plan of the run (of every index for a multi index run) through tool.TablePrinter,
+// it reads both sides and never writes: file outputs are not even opened
+func RunPlan(cmd *cobra.Command) error {
+	input, output := opt.Cfg.Args.Input, opt.Cfg.Args.Output
+	defer func() { opt.Cfg.Args.Input, opt.Cfg.Args.Output = input, output }()
+
+	pairs, err := indexPairs(cmd.Context(), input, output)
+	if err != nil {
+		return err
+	}
+
+	plans := make([]*plan, 0, len(pairs))
+	for _, pair := range pairs {
+		opt.Cfg.Args.Input, opt.Cfg.Args.Output = pair[0], pair[1]
+
+		p, err := planIndex(cmd.Context(), pair[0], pair[1])
+		if err != nil {
+			return fmt.Errorf("plan %s: %w", tool.RedactURI(pair[0]), err)
+		}
+
+		plans = append(plans, p)
+	}
+
+	if len(plans) == 1 {
+		tool.TablePrinter(plans[0], cmd.OutOrStdout())
+	} else {
+		tool.TablePrinter(plans, cmd.OutOrStdout())
+	}
+
+	log.Info("Dry run: nothing was written")
+
+	return nil
+}
+
+func planIndex(ctx context.Context, in, out string) (*plan, error) {
+	input, err := NewIO(ctx, in, model.Input)
+	if err != nil {
+		return nil, err
+	}
+
+	if closer, ok := input.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	// es outputs are only read from, files would be created on open
+	var output model.IO[map[string]any]
+	if isESURI(out) {
+		if output, err = NewIO(ctx, out, model.Output); err != nil {
+			return nil, err
+		}
+	}
+
+	p := &plan{Input: tool.RedactURI(in), Output: tool.RedactURI(out), Type: opt.Cfg.Args.Type}
+	if err = planIO(ctx, p, input, output, in, out); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// planIO fills p from input and output, output is nil for a file (or directory) at out
+func planIO(ctx context.Context, p *plan, input, output model.IO[map[string]any], in, out string) error {
+	var (
+		err                                          error
+		inMapping, inSetting, outMapping, outSetting map[string]any
+	)
+
+	p.Docs, p.EstimatedSize = "-", "-"
+	if p.Type == "data" || p.Type == "all" {
+		docs, err := countQueries(ctx, input)
+		if err != nil {
+			return fmt.Errorf("count docs: %w", err)
+		}
+
+		if opt.Cfg.Args.Max > 0 && docs > opt.Cfg.Args.Max {
+			docs = opt.Cfg.Args.Max
+		}
+
+		p.Docs = fmt.Sprint(docs)
+		if p.EstimatedSize, err = estimateSize(ctx, input, in, docs); err != nil {
+			return fmt.Errorf("estimate size: %w", err)
+		}
+	}
+
+	// es indices and bundles carry a mapping and settings, other files only for the matching --type
+	_, isIndex := input.(model.IndexStater)
+	meta := isIndex || xfile.IsBundle(in)
+
+	if meta || p.Type == "mapping" {
+		if inMapping, err = input.ReadMapping(ctx); err != nil {
+			return fmt.Errorf("read input mapping: %w", err)
+		}
+	}
+
+	if meta || p.Type == "setting" {
+		if inSetting, err = input.ReadSetting(ctx); err != nil {
+			return fmt.Errorf("read input settings: %w", err)
+		}
+	}
+
+	switch stater, ok := output.(model.IndexStater); {
+	case output == nil:
+		p.Target = "new file"
+		if _, err = os.Stat(out); err == nil {
+			p.Target = "file exists"
+		}
+	case !ok:
+		p.Target = "-"
+	default:
+		stat, err := stater.IndexStat(ctx)
+		if err != nil {
+			return fmt.Errorf("stat output index: %w", err)
+		}
+
+		if !stat.Exists {
+			p.Target = "missing, created by es with a dynamic mapping"
+			if p.Type == "all" || opt.Cfg.Args.CreateIndex {
+				p.Target = "missing, created with the input settings and mapping"
+			}
+
+			break
+		}
+
+		p.Target = fmt.Sprintf("exists, %d docs, %s", stat.Docs, tool.HumanBytes(stat.Bytes))
+		if p.Type == "all" || opt.Cfg.Args.CreateIndex {
+			p.Target += ", create index would fail"
+		}
+
+		if outMapping, err = output.ReadMapping(ctx); err != nil {
+			return fmt.Errorf("read output mapping: %w", err)
+		}
+
+		if outSetting, err = output.ReadSetting(ctx); err != nil {
+			return fmt.Errorf("read output settings: %w", err)
+		}
+	}
+
+	p.Mapping = compareMappings(inMapping, outMapping)
+	p.Settings = fmt.Sprintf("input: %s, output: %s", settingSummary(inSetting), settingSummary(outSetting))
+
+	return nil
+}
+
+// countQueries counts the docs matching the query, or every line of the query file
+func countQueries(ctx context.Context, input model.IO[map[string]any]) (int, error) {
+	var (
+		total int
+		err   error
+		qc    = make(chan map[string]any)
+		errc  = make(chan error, 1)
+	)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		errc <- sendQueries(ctx, qc)
+		close(qc)
+	}()
+
+	for query := range qc {
+		if err != nil {
+			continue
+		}
+
+		var n int
+		if n, err = countDocs(ctx, input, query); err != nil {
+			cancel()
+			continue
+		}
+
+		total += n
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	return total, <-errc
+}
+
+// countDocs counts with model.Counter, or reads the input through
+func countDocs(ctx context.Context, input model.IO[map[string]any], query map[string]any) (int, error) {
+	if counter, ok := input.(model.Counter); ok {
+		return counter.Count(ctx, query)
+	}
+
+	defer input.Cleanup()
+
+	total := 0
+	for {
+		items, err := input.ReadData(ctx, opt.Cfg.Args.Limit, query, nil, nil)
+		if err != nil {
+			return 0, err
+		}
+
+		if len(items) == 0 {
+			return total, nil
+		}
+
+		total += len(items)
+	}
+}
+
+// estimateSize scales the primary store size of an es index, or the size on disk of a file, to the docs dumped
+func estimateSize(ctx context.Context, input model.IO[map[string]any], in string, docs int) (string, error) {
+	var (
+		size  int64
+		total int
+	)
+
+	if stater, ok := input.(model.IndexStater); ok {
+		stat, err := stater.IndexStat(ctx)
+		if err != nil {
+			return "", err
+		}
+
+		size, total = stat.Bytes, stat.Docs
+	} else {
+		err := filepath.Walk(in, func(_ string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				size += info.Size()
+			}
+
+			return err
+		})
+		if err != nil {
+			return "", err
+		}
+
+		if total, err = countDocs(ctx, input, nil); err != nil {
+			return "", err
+		}
+	}
+
+	if total == 0 {
+		return tool.HumanBytes(0), nil
+	}
+
+	return tool.HumanBytes(size * int64(docs) / int64(total)), nil
+}
+
+// compareMappings reports the input fields whose type differs in the output mapping
+func compareMappings(in, out map[string]any) string {
+	if in == nil {
+		return "-"
+	}
+
+	inFields := model.MappingFields(in)
+	if out == nil {
+		return fmt.Sprintf("%d fields", len(inFields))
+	}
+
+	var (
+		outFields = model.MappingFields(out)
+		conflicts []string
+		missing   int
+	)
+
+	for field, kind := range inFields {
+		outKind, ok := outFields[field]
+		switch {
+		case !ok:
+			missing++
+		case outKind != kind:
+			conflicts = append(conflicts, fmt.Sprintf("%s (%s => %s)", field, kind, outKind))
+		}
+	}
+
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return "conflicts: " + strings.Join(conflicts, ", ")
+	}
+
+	if missing > 0 {
+		return fmt.Sprintf("compatible, %d fields not in output mapping", missing)
+	}
+
+	return "compatible"
+}
+
+// settingSummary picks the shards and replicas of a ReadSetting result
+func settingSummary(setting map[string]any) string {
+	settings := model.PortableSettings(setting)
+	if settings == nil {
+		return "-"
+	}
+
+	value := func(key string) any {
+		if index, ok := settings["index"].(map[string]any); ok {
+			if v, ok := index[key]; ok {
+				return v
+			}
+		}
+
+		if v, ok := settings["index."+key]; ok {
+			return v
+		}
+
+		return "-"
+	}
+
+	return fmt.Sprintf("shards=%v replicas=%v", value("number_of_shards"), value("number_of_replicas"))
+}
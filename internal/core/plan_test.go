@@ -0,0 +1,115 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/loveuer/esgo2dump/internal/opt"
+	"github.com/loveuer/esgo2dump/pkg/model"
+	"github.com/spf13/cobra"
+)
+
+// statIO is a memIO standing for an es index which counts and stats itself
+type statIO struct {
+	*memIO
+	stat *model.IndexStat
+}
+
+func (x *statIO) Count(ctx context.Context, query map[string]any) (int, error) {
+	return len(x.docs), nil
+}
+
+func (x *statIO) IndexStat(ctx context.Context) (*model.IndexStat, error) { return x.stat, nil }
+
+func TestPlanIO(t *testing.T) {
+	opt.Cfg.Args.Type = "data"
+	opt.Cfg.Args.Max = 40
+	defer func() {
+		opt.Cfg.Args.Type = ""
+		opt.Cfg.Args.Max = 0
+	}()
+
+	mapping := func(props string) map[string]any {
+		return map[string]any{"idx": map[string]any{"mappings": map[string]any{"properties": map[string]any{
+			"id":   map[string]any{"type": "keyword"},
+			"user": map[string]any{"properties": map[string]any{"age": map[string]any{"type": props}}},
+		}}}}
+	}
+	setting := map[string]any{"idx": map[string]any{"settings": map[string]any{"index": map[string]any{"number_of_shards": "3", "number_of_replicas": "1"}}}}
+
+	input := &statIO{memIO: newMemIO(100), stat: &model.IndexStat{Exists: true, Docs: 100, Bytes: 10240}}
+	input.mapping, input.setting = mapping("long"), setting
+
+	output := &statIO{memIO: newMemIO(7), stat: &model.IndexStat{Exists: true, Docs: 7, Bytes: 2048}}
+	output.mapping = mapping("text")
+
+	p := &plan{Type: "data"}
+	if err := planIO(context.Background(), p, input, output, "http://in:9200/idx", "http://out:9200/idx"); err != nil {
+		t.Fatalf("planIO() error = %v", err)
+	}
+
+	want := plan{
+		Type:          "data",
+		Docs:          "40",
+		EstimatedSize: "4.0 KiB",
+		Target:        "exists, 7 docs, 2.0 KiB",
+		Mapping:       "conflicts: user.age (long => text)",
+		Settings:      "input: shards=3 replicas=1, output: -",
+	}
+	if *p != want {
+		t.Errorf("planIO() = %+v, want %+v", *p, want)
+	}
+
+	output.stat = &model.IndexStat{}
+	if err := planIO(context.Background(), p, input, output, "http://in:9200/idx", "http://out:9200/idx"); err != nil {
+		t.Fatalf("planIO() error = %v", err)
+	}
+
+	if p.Target != "missing, created by es with a dynamic mapping" || p.Mapping != "3 fields" {
+		t.Errorf("planIO() on a missing index target = %s, mapping = %s", p.Target, p.Mapping)
+	}
+}
+
+func TestRunPlan_File(t *testing.T) {
+	dir := t.TempDir()
+	in, out := filepath.Join(dir, "data.json"), filepath.Join(dir, "out", "data.json")
+
+	lines := `{"_id":"1","_source":{"age":10}}` + "\n" + `{"_id":"2","_source":{"age":20}}` + "\n" + `{"_id":"3","_source":{"age":30}}` + "\n"
+	if err := os.WriteFile(in, []byte(lines), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	opt.Cfg.Args.Input, opt.Cfg.Args.Output = in, out
+	opt.Cfg.Args.Type = "data"
+	opt.Cfg.Args.Limit = 2
+	opt.Cfg.Args.Query = `{"range":{"age":{"gte":20}}}`
+	defer func() {
+		opt.Cfg.Args.Input, opt.Cfg.Args.Output = "", ""
+		opt.Cfg.Args.Type = ""
+		opt.Cfg.Args.Limit = 0
+		opt.Cfg.Args.Query = ""
+	}()
+
+	var buf bytes.Buffer
+	cmd := &cobra.Command{}
+	cmd.SetContext(context.Background())
+	cmd.SetOut(&buf)
+
+	if err := RunPlan(cmd); err != nil {
+		t.Fatalf("RunPlan() error = %v", err)
+	}
+
+	for _, row := range []string{"Docs", "| 2 ", "new file"} {
+		if !strings.Contains(buf.String(), row) {
+			t.Errorf("RunPlan() printed\n%s\nwithout %q", buf.String(), row)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Dir(out)); !os.IsNotExist(err) {
+		t.Errorf("RunPlan() created the output directory, err = %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/loveuer/esgo2dump/internal/opt"
+	"github.com/loveuer/esgo2dump/pkg/log"
+	"github.com/loveuer/esgo2dump/pkg/model"
+	"github.com/spf13/cobra"
+)
+
+// RunAll dumps a whole index: settings, mapping and aliases first, so the data lands in a ready index.
+// es outputs create the index with settings and mapping in one request
+func RunAll(cmd *cobra.Command, input model.IO[map[string]any], output model.IO[map[string]any]) error {
+	for _, item := range []model.IO[map[string]any]{input, output} {
+		if closer, ok := item.(io.Closer); ok {
+			defer closer.Close()
+		}
+	}
+
+	if _, ok := output.(model.IndexCreator); ok {
+		if err := CreateIndex(cmd, input, output); err != nil {
+			return err
+		}
+	} else {
+		if err := RunMapping(cmd, input, output); err != nil {
+			return err
+		}
+
+		if err := RunSetting(cmd, input, output); err != nil {
+			return err
+		}
+	}
+
+	if err := runAliases(cmd, input, output); err != nil {
+		return err
+	}
+
+	return RunData(cmd, input, output)
+}
+
+// CreateIndex creates the output index with the settings and mapping of the input, before any data is written.
+// an existing output index is a conflict, es would otherwise keep its own settings and mapping silently
+func CreateIndex(cmd *cobra.Command, input model.IO[map[string]any], output model.IO[map[string]any]) error {
+	creator, ok := output.(model.IndexCreator)
+	if !ok {
+		return fmt.Errorf("create index: output %s is not an es index", opt.Cfg.Args.Output)
+	}
+
+	setting, err := input.ReadSetting(cmd.Context())
+	if err != nil {
+		return fmt.Errorf("create index: read input settings: %w", err)
+	}
+
+	mapping, err := input.ReadMapping(cmd.Context())
+	if err != nil {
+		return fmt.Errorf("create index: read input mapping: %w", err)
+	}
+
+	if err = creator.CreateIndex(cmd.Context(), setting, mapping); err != nil {
+		if errors.Is(err, model.ErrIndexExists) {
+			return fmt.Errorf("create index: %w, remove it or pick another output index", err)
+		}
+
+		return fmt.Errorf("create index: %w", err)
+	}
+
+	log.Info("Dump: created index with input settings and mapping")
+
+	return nil
+}
+
+func runAliases(cmd *cobra.Command, input model.IO[map[string]any], output model.IO[map[string]any]) error {
+	in, ok := input.(model.Aliaser)
+	if !ok {
+		log.Debug("Dump: input has no aliases")
+		return nil
+	}
+
+	out, ok := output.(model.Aliaser)
+	if !ok {
+		log.Warn("Dump: output does not support aliases, skip them")
+		return nil
+	}
+
+	aliases, err := in.ReadAliases(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	if len(model.UnwrapAliases(aliases)) == 0 {
+		return nil
+	}
+
+	return out.WriteAliases(cmd.Context(), aliases)
+}
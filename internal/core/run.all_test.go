@@ -0,0 +1,136 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/loveuer/esgo2dump/internal/opt"
+	"github.com/loveuer/esgo2dump/internal/xfile"
+	"github.com/loveuer/esgo2dump/pkg/model"
+	"github.com/spf13/cobra"
+)
+
+// indexIO is a memIO standing for an es index, it records CreateIndex and aliases
+type indexIO struct {
+	*memIO
+	created map[string]any
+	aliases map[string]any
+}
+
+func (x *indexIO) CreateIndex(ctx context.Context, setting, mapping map[string]any) error {
+	x.created = model.IndexBody(setting, mapping)
+	return nil
+}
+
+func (x *indexIO) ReadAliases(ctx context.Context) (map[string]any, error) { return x.aliases, nil }
+
+func (x *indexIO) WriteAliases(ctx context.Context, aliases map[string]any) error {
+	x.aliases = aliases
+	return nil
+}
+
+func TestRunAll_Bundle(t *testing.T) {
+	opt.Cfg.Args.Limit = 3
+	opt.Cfg.Args.Type = "all"
+	defer func() {
+		opt.Cfg.Args.Limit = 0
+		opt.Cfg.Args.Type = ""
+	}()
+
+	var (
+		path    = filepath.Join(t.TempDir(), "bundle.tar")
+		mapping = map[string]any{"src": map[string]any{"mappings": map[string]any{"properties": map[string]any{"id": map[string]any{"type": "keyword"}}}}}
+		setting = map[string]any{"src": map[string]any{"settings": map[string]any{"index": map[string]any{
+			"number_of_shards": "3",
+			"uuid":             "x",
+			"creation_date":    "1",
+			"provided_name":    "src",
+			"version":          map[string]any{"created": "7170099"},
+		}}}}
+		aliases = map[string]any{"src": map[string]any{"aliases": map[string]any{"current": map[string]any{}}}}
+	)
+
+	source := &indexIO{memIO: newMemIO(10), aliases: aliases}
+	source.mapping, source.setting = mapping, setting
+
+	output, err := NewIO(context.Background(), path, model.Output)
+	if err != nil {
+		t.Fatalf("NewIO(bundle output) error = %v", err)
+	}
+
+	cmd := &cobra.Command{}
+	cmd.SetContext(context.Background())
+	if err = RunAll(cmd, source, output); err != nil {
+		t.Fatalf("RunAll(index -> bundle) error = %v", err)
+	}
+
+	input, err := xfile.NewBundle(path, "", model.Input)
+	if err != nil {
+		t.Fatalf("NewBundle(input) error = %v", err)
+	}
+
+	target := &indexIO{memIO: &memIO{}}
+
+	cmd = &cobra.Command{}
+	cmd.SetContext(context.Background())
+	if err = RunAll(cmd, input, target); err != nil {
+		t.Fatalf("RunAll(bundle -> index) error = %v", err)
+	}
+
+	want := map[string]any{
+		"settings": map[string]any{"index": map[string]any{"number_of_shards": "3"}},
+		"mappings": map[string]any{"properties": map[string]any{"id": map[string]any{"type": "keyword"}}},
+	}
+	if !reflect.DeepEqual(target.created, want) {
+		t.Errorf("CreateIndex() body = %v, want %v", target.created, want)
+	}
+
+	if !reflect.DeepEqual(target.aliases, aliases) {
+		t.Errorf("WriteAliases() = %v, want %v", target.aliases, aliases)
+	}
+
+	if len(target.written) != 10 {
+		t.Errorf("RunAll() wrote %d docs, want 10", len(target.written))
+	}
+}
+
+// existingIO is an es index which is already there
+type existingIO struct {
+	*memIO
+}
+
+func (x *existingIO) CreateIndex(ctx context.Context, setting, mapping map[string]any) error {
+	return model.ErrIndexExists
+}
+
+func TestCreateIndex(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  model.IO[map[string]any]
+		wantErr error
+	}{
+		{"create", &indexIO{memIO: &memIO{}}, nil},
+		{"exists", &existingIO{memIO: &memIO{}}, model.ErrIndexExists},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := &cobra.Command{}
+			cmd.SetContext(context.Background())
+
+			err := CreateIndex(cmd, newMemIO(1), tt.output)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("CreateIndex() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	cmd := &cobra.Command{}
+	cmd.SetContext(context.Background())
+	if err := CreateIndex(cmd, newMemIO(1), &memIO{}); err == nil {
+		t.Error("CreateIndex() into a non es output should fail")
+	}
+}
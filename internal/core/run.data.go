@@ -2,7 +2,9 @@ package core
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -20,85 +22,123 @@ func RunData(cmd *cobra.Command, input, output model.IO[map[string]any]) error {
 	var (
 		err error
 		// query chan
-		qc = make(chan map[string]any)
-		// error chan
-		ec = make(chan error)
-		// done chan
-		wc    = &sync.WaitGroup{}
-		total = 0
+		qc      = make(chan map[string]any)
+		wc      = &sync.WaitGroup{}
+		counter = newProgress()
+		workers = opt.Cfg.Args.Workers
 	)
 
-	wc.Add(2)
+	if workers < 1 {
+		workers = 1
+	}
 
-	go func() {
-		var ok bool
+	readers := []model.IO[map[string]any]{input}
+	if opt.Cfg.Args.Slices > 1 {
+		if slicer, ok := input.(model.Slicer[map[string]any]); ok {
+			if readers, err = slicer.Slices(opt.Cfg.Args.Slices); err != nil {
+				return err
+			}
+		} else {
+			log.Warn("Dump: input does not support slices, read with single cursor")
+		}
+	}
 
-		defer wc.Done()
+	ckpt, err := newCheckpointer(opt.Cfg.Args.Checkpoint, len(readers))
+	if err != nil {
+		return err
+	}
 
-		err, ok = <-ec
-		if !ok {
-			return
-		}
+	hooks, err := newDocHooks()
+	if err != nil {
+		return err
+	}
 
-		log.Error(err.Error())
-		os.Exit(1)
+	dl := newDeadLetter(opt.Cfg.Args.DeadLetter)
+	defer dl.close()
 
-	}()
+	if err = ckpt.restore(cmd.Context(), output, counter); err != nil {
+		return err
+	}
 
-	go func() {
-		var (
-			wroteCount = 0
-			items      []map[string]any
-		)
+	// a checkpoint needs every reader's batches written in read order
+	if ckpt != nil && workers > 1 {
+		log.Warn("Dump: checkpoint writes with a single worker, ignore workers = %d", workers)
+		workers = 1
+	}
+
+	// the first failure cancels the command context, which stops every reader, writer and the query feed
+	parent := cmd.Context()
+	ctx, cancel := context.WithCancelCause(parent)
+	defer cancel(nil)
+	cmd.SetContext(ctx)
+	// the next index of a multi index dump starts from the parent context again
+	defer cmd.SetContext(parent)
 
+	wc.Add(1)
+	go func() {
 		defer wc.Done()
 
+		line := 0
 		for query := range qc {
-			for {
-				limit := tool.CalculateLimit(opt.Cfg.Args.Limit, total, opt.Cfg.Args.Max)
-				log.Debug("one-step dump begin: arg.limit = %d, total = %d, arg.max = %d, calculate.limit = %d", opt.Cfg.Args.Limit, total, opt.Cfg.Args.Max, limit)
-				if limit == 0 {
-					break
-				}
-
-				log.Debug("one-step dump start read: arg.limit = %d, total = %d, arg.max = %d, calculate.limit = %d", opt.Cfg.Args.Limit, total, opt.Cfg.Args.Max, limit)
-				if items, err = input.ReadData(
-					cmd.Context(),
-					limit,
-					query,
-					lo.Filter(strings.Split(opt.Cfg.Args.Field, ","), func(x string, _ int) bool { return x != "" }),
-					lo.Filter(strings.Split(opt.Cfg.Args.Sort, ","), func(x string, _ int) bool { return x != "" }),
-				); err != nil {
-					ec <- err
-					return
-				}
-
-				if len(items) == 0 {
-					input.Cleanup()
-					break
-				}
-
-				log.Debug("one-step dump start write: arg.limit = %d, total = %d, arg.max = %d, calculate.limit = %d, got = %d", opt.Cfg.Args.Limit, total, opt.Cfg.Args.Max, limit, len(items))
-				if wroteCount, err = output.WriteData(cmd.Context(), items); err != nil {
-					ec <- err
-					return
-				}
-
-				total += wroteCount
-
-				if wroteCount != len(items) {
-					ec <- fmt.Errorf("got items %d, but wrote %d", len(items), wroteCount)
-					return
-				}
-
-				log.Info("Dump: dump data success = %d total = %d", wroteCount, total)
+			line++
+			if ckpt.skip(line) {
+				log.Debug("Dump: skip query[%06d] dumped before checkpoint", line)
+				continue
+			}
+
+			if err := dumpQuery(ctx, readers, output, query, line, workers, counter, ckpt, hooks, dl); err != nil {
+				cancel(err)
+				return
 			}
 		}
 	}()
 
+	if err = sendQueries(ctx, qc); err != nil {
+		cancel(err)
+	}
+
+	// close query chan to stop trans_io_goroutine
+	close(qc)
+
+	wc.Wait()
+
+	// cleanup output (e.g., close split files)
+	output.Cleanup()
+
+	if ctx.Err() != nil {
+		ckpt.save()
+		return context.Cause(ctx)
+	}
+
+	// only a complete dump is finalized, e.g. a bundle gets its manifest
+	if finalizer, ok := output.(model.Finalizer); ok {
+		if err = finalizer.Finalize(); err != nil {
+			return err
+		}
+	}
+
+	ckpt.finish()
+
+	log.Info("Dump: dump all data success, total = %d", counter.Total())
+
+	return nil
+}
+
+// sendQueries feeds the query (or every line of the query file) into qc until ctx is done
+func sendQueries(ctx context.Context, qc chan<- map[string]any) error {
+	send := func(query map[string]any) error {
+		select {
+		case qc <- query:
+			return nil
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		}
+	}
+
 	switch {
 	case opt.Cfg.Args.QueryFile != "":
 		var (
+			err error
 			// query file
 			qf         *os.File
 			queryCount = 0
@@ -117,7 +157,9 @@ func RunData(cmd *cobra.Command, input, output model.IO[map[string]any]) error {
 				return err
 			}
 
-			qc <- qm
+			if err = send(qm); err != nil {
+				return err
+			}
 
 			log.Debug("Dump: queries[%06d] = %s", queryCount, string(bs))
 		}
@@ -129,26 +171,275 @@ func RunData(cmd *cobra.Command, input, output model.IO[map[string]any]) error {
 			qm = make(map[string]any)
 		)
 
-		if err = json.Unmarshal([]byte(opt.Cfg.Args.Query), &qm); err != nil {
+		if err := json.Unmarshal([]byte(opt.Cfg.Args.Query), &qm); err != nil {
 			log.Debug("unmarshal arg.query string err, query = %s ,err = %s", opt.Cfg.Args.Query, err.Error())
 			return err
 		}
 
-		qc <- qm
+		return send(qm)
 	default:
-		qc <- nil
+		return send(nil)
 	}
 
-	// close query chan to stop trans_io_goroutine
-	close(qc)
-	close(ec)
+	return nil
+}
 
-	wc.Wait()
+// progress tracks the --max budget shared by every reader of one run
+type progress struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	// reserved counts docs read or booked by reads in flight, reading counts the reads in flight
+	reserved int
+	reading  int
+	total    int
+}
 
-	// cleanup output (e.g., close split files)
-	output.Cleanup()
+func newProgress() *progress {
+	p := &progress{}
+	p.cond = sync.NewCond(&p.mu)
+
+	return p
+}
+
+// reserve books the next read limit so concurrent readers never exceed --max together.
+// when the budget is booked up by reads in flight, it waits for them, as they may come back short
+func (p *progress) reserve() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for {
+		limit := tool.CalculateLimit(opt.Cfg.Args.Limit, p.reserved, opt.Cfg.Args.Max)
+		if limit > 0 {
+			p.reserved += limit
+			p.reading++
+			return limit
+		}
+
+		if p.reading == 0 {
+			return 0
+		}
+
+		p.cond.Wait()
+	}
+}
+
+// read settles a reservation once its read returned, the unread part goes back to the budget
+func (p *progress) read(reserved, read int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.reserved -= reserved - read
+	p.reading--
+	p.cond.Broadcast()
+}
+
+// wrote counts the written items
+func (p *progress) wrote(n int) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.total += n
+
+	return p.total
+}
+
+func (p *progress) Total() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.total
+}
 
-	log.Info("Dump: dump all data success, total = %d", total)
+// docHooks rewrite every read batch before it is written: --transform first, then --mask has the last word
+type docHooks struct {
+	transform *transformer
+	mask      *masker
+}
+
+func newDocHooks() (*docHooks, error) {
+	var (
+		err   error
+		hooks = &docHooks{}
+	)
+
+	if hooks.transform, err = newTransformer(opt.Cfg.Args.Transform); err != nil {
+		return nil, err
+	}
+
+	if hooks.mask, err = newMasker(opt.Cfg.Args.Mask); err != nil {
+		return nil, err
+	}
+
+	return hooks, nil
+}
+
+func (h *docHooks) apply(items []map[string]any) ([]map[string]any, error) {
+	items, err := h.transform.apply(items)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = h.mask.apply(items); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// batch is one read of a reader on its way to the writers
+type batch struct {
+	slot int
+	// read counts the docs the reader has read up to and including this batch, position is the reader position after it
+	read     int
+	position map[string]any
+	items    []map[string]any
+}
+
+// dumpQuery runs one query as a pipeline: every reader pages into a bounded batch chan concurrently,
+// workers write the batches to output. readers block when the chan is full, the chan is closed once all readers are done
+func dumpQuery(ctx context.Context, readers []model.IO[map[string]any], output model.IO[map[string]any], query map[string]any, line, workers int, counter *progress, ckpt *checkpointer, hooks *docHooks, dl *deadLetter) error {
+	var (
+		rg        sync.WaitGroup
+		wg        sync.WaitGroup
+		bc        = make(chan *batch, workers*opt.BatchBufferPerWorker)
+		errs      = make([]error, len(readers)+workers)
+		positions = ckpt.begin(line)
+	)
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	for i := range readers {
+		rg.Add(1)
+		go func(i int) {
+			defer rg.Done()
+
+			pos := readerPosition{}
+			if positions != nil {
+				pos = positions[i]
+			}
+
+			if errs[i] = readBatches(ctx, i, readers[i], query, pos, bc, counter, hooks); errs[i] != nil {
+				cancel(errs[i])
+			}
+		}(i)
+	}
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			if errs[len(readers)+i] = writeBatches(ctx, output, bc, counter, ckpt, dl); errs[len(readers)+i] != nil {
+				cancel(errs[len(readers)+i])
+			}
+		}(i)
+	}
+
+	rg.Wait()
+	close(bc)
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	// the parent context may be done without any reader or writer failing, e.g. on interrupt
+	return context.Cause(ctx)
+}
+
+// readBatches pages one reader into bc until it is drained, --max is reached or ctx is done
+func readBatches(ctx context.Context, slot int, input model.IO[map[string]any], query map[string]any, pos readerPosition, bc chan<- *batch, counter *progress, hooks *docHooks) error {
+	var (
+		err     error
+		items   []map[string]any
+		pending []map[string]any
+		read    = pos.Read
+	)
+
+	// clear the cursor whether the reader is drained, hits --max or fails
+	defer input.Cleanup()
+
+	// the docs past the checkpoint in the last skipped page go first
+	if pending, err = resumeReader(ctx, input, query, pos); err != nil {
+		return err
+	}
+
+	for ctx.Err() == nil {
+		limit := counter.reserve()
+		log.Debug("one-step dump begin: arg.limit = %d, arg.max = %d, calculate.limit = %d", opt.Cfg.Args.Limit, opt.Cfg.Args.Max, limit)
+		if limit == 0 {
+			return nil
+		}
+
+		// the reader position is past the pending docs, their batches resume by count
+		var cursor map[string]any
+		if n := tool.Min(limit, len(pending)); n > 0 {
+			items, pending = pending[:n], pending[n:]
+		} else {
+			items, err = input.ReadData(
+				ctx,
+				limit,
+				query,
+				splitArg(opt.Cfg.Args.Field),
+				splitArg(opt.Cfg.Args.Sort),
+			)
+			cursor = position(input)
+		}
+		counter.read(limit, len(items))
+
+		if err != nil {
+			return err
+		}
+
+		if len(items) == 0 {
+			return nil
+		}
+
+		// positions count what was read, whatever the hooks drop
+		read += len(items)
+
+		if items, err = hooks.apply(items); err != nil {
+			return err
+		}
+
+		select {
+		case bc <- &batch{slot: slot, read: read, position: cursor, items: items}:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// writeBatches writes batches from bc until it is closed, batches left after ctx is done are dropped.
+// docs the output rejects go to the dead letter file when there is one
+func writeBatches(ctx context.Context, output model.IO[map[string]any], bc <-chan *batch, counter *progress, ckpt *checkpointer, dl *deadLetter) error {
+	for b := range bc {
+		if ctx.Err() != nil {
+			continue
+		}
+
+		log.Debug("one-step dump start write: arg.limit = %d, arg.max = %d, got = %d", opt.Cfg.Args.Limit, opt.Cfg.Args.Max, len(b.items))
+		wroteCount, deadCount, err := ckpt.writeData(ctx, b, output, dl)
+		if err != nil {
+			return err
+		}
+
+		total := counter.wrote(wroteCount + deadCount)
+
+		if wroteCount+deadCount != len(b.items) {
+			return fmt.Errorf("got items %d, but wrote %d and dead lettered %d", len(b.items), wroteCount, deadCount)
+		}
+
+		log.Info("Dump: dump data success = %d total = %d", wroteCount, total)
+	}
 
 	return nil
 }
+
+// splitArg splits a ',' separated arg, dropping empty parts
+func splitArg(arg string) []string {
+	return lo.Filter(strings.Split(arg, ","), func(x string, _ int) bool { return x != "" })
+}
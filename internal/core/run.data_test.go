@@ -0,0 +1,230 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/loveuer/esgo2dump/internal/opt"
+	"github.com/loveuer/esgo2dump/internal/tool"
+	"github.com/loveuer/esgo2dump/pkg/model"
+	"github.com/spf13/cobra"
+)
+
+// memIO is an in-memory model.IO, reads hand out docs in order and writes collect them
+type memIO struct {
+	mu       sync.Mutex
+	docs     []map[string]any
+	offset   int
+	written  []map[string]any
+	cleanups int
+	slices   []*memIO
+	// writes fail with failWrite once failAfter docs are written
+	failWrite error
+	failAfter int
+	// reject fails the docs with these _id for good, like an es mapping error
+	reject  map[any]bool
+	mapping map[string]any
+	setting map[string]any
+	// page makes reads fetch pages of this size and cut them to the limit like es scrolls, the cut docs are lost
+	page int
+}
+
+func (m *memIO) Cleanup() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cleanups++
+}
+
+func (m *memIO) ReadData(ctx context.Context, limit int, query map[string]any, fields []string, sort []string) ([]map[string]any, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	end := m.offset + limit
+	if m.page > 0 {
+		end = m.offset + m.page
+	}
+
+	if end > len(m.docs) {
+		end = len(m.docs)
+	}
+
+	items := m.docs[m.offset:end]
+	m.offset = end
+
+	return items[:tool.Min(limit, len(items))], nil
+}
+
+func (m *memIO) WriteData(ctx context.Context, items []map[string]any) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.failWrite != nil && len(m.written)+len(items) > m.failAfter {
+		return 0, m.failWrite
+	}
+
+	var failures []*model.BulkFailure
+	for _, item := range items {
+		if m.reject[item["_id"]] {
+			failures = append(failures, &model.BulkFailure{Item: item, Status: 400, Type: "mapper_parsing_exception", Reason: "failed to parse"})
+			continue
+		}
+
+		m.written = append(m.written, item)
+	}
+
+	if len(failures) > 0 {
+		return len(items) - len(failures), &model.BulkError{Failures: failures}
+	}
+
+	return len(items), nil
+}
+
+func (m *memIO) ReadMapping(ctx context.Context) (map[string]any, error) { return m.mapping, nil }
+
+func (m *memIO) WriteMapping(ctx context.Context, mapping map[string]any) error {
+	m.mapping = mapping
+	return nil
+}
+
+func (m *memIO) ReadSetting(ctx context.Context) (map[string]any, error) { return m.setting, nil }
+
+func (m *memIO) WriteSetting(ctx context.Context, setting map[string]any) error {
+	m.setting = setting
+	return nil
+}
+
+// Slices deals the docs round-robin into n slices
+func (m *memIO) Slices(n int) ([]model.IO[map[string]any], error) {
+	m.slices = make([]*memIO, n)
+	for i := range m.slices {
+		m.slices[i] = &memIO{}
+	}
+
+	for i, doc := range m.docs {
+		m.slices[i%n].docs = append(m.slices[i%n].docs, doc)
+	}
+
+	ios := make([]model.IO[map[string]any], 0, n)
+	for _, s := range m.slices {
+		ios = append(ios, s)
+	}
+
+	return ios, nil
+}
+
+func newMemIO(n int) *memIO {
+	m := &memIO{}
+	for i := 0; i < n; i++ {
+		m.docs = append(m.docs, map[string]any{"_id": fmt.Sprint(i)})
+	}
+
+	return m
+}
+
+func TestRunData_Slices(t *testing.T) {
+	tests := []struct {
+		name    string
+		docs    int
+		slices  int
+		workers int
+		max     int
+		want    int
+	}{
+		{"single cursor", 25, 0, 1, 0, 25},
+		{"sliced", 25, 4, 1, 0, 25},
+		{"sliced with max", 25, 4, 1, 12, 12},
+		{"sliced with max above total", 25, 3, 1, 100, 25},
+		{"workers", 25, 0, 4, 0, 25},
+		{"sliced workers with max", 25, 4, 3, 12, 12},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opt.Cfg.Args.Limit = 3
+			opt.Cfg.Args.Slices = tt.slices
+			opt.Cfg.Args.Max = tt.max
+			opt.Cfg.Args.Workers = tt.workers
+			defer func() {
+				opt.Cfg.Args.Limit = 0
+				opt.Cfg.Args.Slices = 0
+				opt.Cfg.Args.Max = 0
+				opt.Cfg.Args.Workers = 0
+			}()
+
+			input, output := newMemIO(tt.docs), &memIO{}
+
+			cmd := &cobra.Command{}
+			cmd.SetContext(context.Background())
+			if err := RunData(cmd, input, output); err != nil {
+				t.Fatalf("RunData() error = %v", err)
+			}
+
+			if len(output.written) != tt.want {
+				t.Errorf("RunData() wrote %d docs, want %d", len(output.written), tt.want)
+			}
+
+			seen := make(map[any]bool)
+			for _, doc := range output.written {
+				if seen[doc["_id"]] {
+					t.Errorf("RunData() wrote doc %v twice", doc["_id"])
+				}
+				seen[doc["_id"]] = true
+			}
+
+			if tt.slices > 1 {
+				if len(input.slices) != tt.slices {
+					t.Fatalf("RunData() used %d slices, want %d", len(input.slices), tt.slices)
+				}
+
+				for i, s := range input.slices {
+					if s.cleanups != 1 {
+						t.Errorf("slice %d cleaned up %d times, want 1", i, s.cleanups)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRunData_WriteError(t *testing.T) {
+	opt.Cfg.Args.Limit = 2
+	opt.Cfg.Args.Slices = 3
+	opt.Cfg.Args.Workers = 2
+	defer func() {
+		opt.Cfg.Args.Limit = 0
+		opt.Cfg.Args.Slices = 0
+		opt.Cfg.Args.Workers = 0
+	}()
+
+	wantErr := errors.New("bulk rejected")
+	input, output := newMemIO(100), &memIO{failWrite: wantErr, failAfter: 10}
+
+	parent, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cmd := &cobra.Command{}
+	cmd.SetContext(parent)
+
+	err := RunData(cmd, input, output)
+	if !errors.Is(err, wantErr) {
+		t.Fatalf("RunData() error = %v, want %v", err, wantErr)
+	}
+
+	// the failure cancels the dump context only, the command gets its parent context back
+	if cmd.Context() != parent || parent.Err() != nil {
+		t.Error("RunData() should restore the parent command context on failure")
+	}
+
+	for i, s := range input.slices {
+		if s.cleanups != 1 {
+			t.Errorf("slice %d cleaned up %d times, want 1", i, s.cleanups)
+		}
+	}
+
+	if output.cleanups != 1 {
+		t.Errorf("output cleaned up %d times, want 1", output.cleanups)
+	}
+}
@@ -0,0 +1,32 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/loveuer/esgo2dump/internal/opt"
+	"github.com/loveuer/esgo2dump/pkg/model"
+	"github.com/spf13/cobra"
+)
+
+// Run dumps --type from input to output
+func Run(cmd *cobra.Command, input model.IO[map[string]any], output model.IO[map[string]any]) error {
+	switch opt.Cfg.Args.Type {
+	case "data":
+		// a resumed run created the index before its checkpoint
+		if opt.Cfg.Args.CreateIndex && !opt.Cfg.Args.Resume {
+			if err := CreateIndex(cmd, input, output); err != nil {
+				return err
+			}
+		}
+
+		return RunData(cmd, input, output)
+	case "mapping":
+		return RunMapping(cmd, input, output)
+	case "setting":
+		return RunSetting(cmd, input, output)
+	case "all":
+		return RunAll(cmd, input, output)
+	}
+
+	return fmt.Errorf("unknown args: type = %s", opt.Cfg.Args.Type)
+}
@@ -0,0 +1,289 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/loveuer/esgo2dump/internal/opt"
+	"github.com/loveuer/esgo2dump/internal/tool"
+	"github.com/loveuer/esgo2dump/internal/xfile"
+	"github.com/loveuer/esgo2dump/pkg/log"
+	"github.com/loveuer/esgo2dump/pkg/model"
+	"github.com/spf13/cobra"
+)
+
+// IsMultiIndex reports whether an es uri names several indices, as a list or a wildcard pattern
+func IsMultiIndex(uri string) bool {
+	return strings.ContainsAny(ExtractIndexName(uri), ",*")
+}
+
+// MultiIndex reports whether the dump runs index by index: several es indices into a directory
+// or an es uri without index, or a multi index dump directory back
+func MultiIndex(input, output string) bool {
+	if xfile.IsIndexList(input) {
+		return true
+	}
+
+	if !IsMultiIndex(input) {
+		return false
+	}
+
+	if isESURI(output) {
+		return ExtractIndexName(output) == ""
+	}
+
+	if info, err := os.Stat(output); err == nil {
+		return info.IsDir()
+	}
+
+	return strings.HasSuffix(output, string(os.PathSeparator))
+}
+
+// RunMulti dumps every index of the input on its own: es indices go to <output>/<index>.json
+// (or split directories and bundles, see xfile.IndexPath) listed in indices.json, which restores them by name
+func RunMulti(cmd *cobra.Command) error {
+	input, output := opt.Cfg.Args.Input, opt.Cfg.Args.Output
+	defer func() { opt.Cfg.Args.Input, opt.Cfg.Args.Output = input, output }()
+
+	if isESURI(output) && ExtractIndexName(output) != "" {
+		return fmt.Errorf("multi index dump needs an output directory or an es uri without index, got index %s", ExtractIndexName(output))
+	}
+
+	entries, err := sourceIndices(cmd.Context(), input)
+	if err != nil {
+		return err
+	}
+
+	if len(entries) == 0 {
+		return fmt.Errorf("no index found for %s", input)
+	}
+
+	var list *xfile.IndexList
+	if !isESURI(output) {
+		if err = os.MkdirAll(output, 0o755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+
+		list = xfile.NewIndexList(opt.Cfg.Args.Type)
+	}
+
+	for i, entry := range entries {
+		log.Info("Dump: index %s (%d/%d)", entry.Index, i+1, len(entries))
+
+		target, path := indexTarget(output, entry.Index)
+		if list != nil {
+			list.Indices = append(list.Indices, xfile.IndexEntry{Index: entry.Index, Path: path})
+		}
+
+		if err = runIndex(cmd, entry.Path, target); err != nil {
+			return fmt.Errorf("index %s: %w", entry.Index, err)
+		}
+
+		// listed as soon as done, so an interrupted dump restores what it got
+		if list != nil {
+			if err = xfile.WriteIndexList(output, list); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// runIndex dumps one index of a multi index run, its ios are closed before the next index opens its own
+func runIndex(cmd *cobra.Command, input, output string) error {
+	// NewIO names split parts and bundles after the input index
+	opt.Cfg.Args.Input, opt.Cfg.Args.Output = input, output
+
+	in, err := NewIO(cmd.Context(), input, model.Input)
+	if err != nil {
+		return err
+	}
+
+	if closer, ok := in.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	out, err := NewIO(cmd.Context(), output, model.Output)
+	if err != nil {
+		return err
+	}
+
+	if closer, ok := out.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	return Run(cmd, in, out)
+}
+
+// sourceIndices lists the indices of a multi index input with where to read each one
+func sourceIndices(ctx context.Context, input string) ([]xfile.IndexEntry, error) {
+	if !isESURI(input) {
+		list, err := xfile.ReadIndexList(input)
+		if err != nil {
+			return nil, err
+		}
+
+		if list.Type != opt.Cfg.Args.Type {
+			log.Warn("%s holds a type=%s dump, restoring type=%s", input, list.Type, opt.Cfg.Args.Type)
+		}
+
+		entries := make([]xfile.IndexEntry, 0, len(list.Indices))
+		for _, entry := range list.Indices {
+			entries = append(entries, xfile.IndexEntry{Index: entry.Index, Path: filepath.Join(input, entry.Path)})
+		}
+
+		return entries, nil
+	}
+
+	names, err := resolveIndices(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]xfile.IndexEntry, 0, len(names))
+	for _, name := range names {
+		entries = append(entries, xfile.IndexEntry{Index: name, Path: indexURI(input, name)})
+	}
+
+	return entries, nil
+}
+
+// resolveIndices expands the index list or pattern of an es uri into the open indices it names, through _cat/indices
+// asked of the nodes in turn like probeVersion. dot (hidden and system) indices only match a pattern starting with a dot
+func resolveIndices(ctx context.Context, uri string) ([]string, error) {
+	target, err := tool.ParseURI(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := NewConn(model.Input, target)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		errs    []error
+		rr      *resty.Response
+		hc      = newHTTPClient(conn)
+		pattern = ExtractIndexName(uri)
+		rows    []struct {
+			Index  string `json:"index"`
+			Status string `json:"status"`
+		}
+	)
+
+	for _, host := range strings.Split(target.Host, ",") {
+		catURL := fmt.Sprintf("%s://%s/_cat/indices/%s", target.Scheme, host, pattern)
+
+		timeout, cancel := tool.TimeoutCtx(ctx, opt.Timeout)
+		rr, err = hc.R().SetContext(timeout).SetQueryParams(map[string]string{"format": "json", "h": "index,status"}).Get(catURL)
+		cancel()
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, context.Cause(ctx)
+			}
+
+			log.Debug("resolve indices on %s failed, err = %s", host, err.Error())
+			errs = append(errs, fmt.Errorf("%s: %w", host, err))
+			rr = nil
+			continue
+		}
+
+		if rr.StatusCode() >= 500 {
+			errs = append(errs, fmt.Errorf("%s: status=%d, msg=%s", host, rr.StatusCode(), rr.String()))
+			rr = nil
+			continue
+		}
+
+		break
+	}
+
+	if rr == nil {
+		return nil, fmt.Errorf("resolve indices: %w", errors.Join(errs...))
+	}
+
+	if rr.StatusCode() != 200 {
+		return nil, fmt.Errorf("resolve indices: status=%d, msg=%s", rr.StatusCode(), rr.String())
+	}
+
+	if err = json.Unmarshal(rr.Body(), &rows); err != nil {
+		return nil, fmt.Errorf("resolve indices: %w", err)
+	}
+
+	names := make([]string, 0, len(rows))
+	for _, row := range rows {
+		if row.Status != "" && row.Status != "open" {
+			log.Debug("resolve indices: skip %s index %s", row.Status, row.Index)
+			continue
+		}
+
+		if strings.HasPrefix(row.Index, ".") && !strings.HasPrefix(pattern, ".") {
+			continue
+		}
+
+		names = append(names, row.Index)
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// indexTarget is where index goes in a multi index dump: the index of an es output,
+// or a path in an output directory, also returned relative to it
+func indexTarget(output, index string) (string, string) {
+	if isESURI(output) {
+		return indexURI(output, index), ""
+	}
+
+	path := xfile.IndexPath(index, opt.Cfg.Args.Type)
+
+	return filepath.Join(output, path), path
+}
+
+// indexPairs are the input and output of every index of a run, the run itself unless it is a multi index one
+func indexPairs(ctx context.Context, input, output string) ([][2]string, error) {
+	if !MultiIndex(input, output) {
+		return [][2]string{{input, output}}, nil
+	}
+
+	entries, err := sourceIndices(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	pairs := make([][2]string, 0, len(entries))
+	for _, entry := range entries {
+		target, _ := indexTarget(output, entry.Index)
+		pairs = append(pairs, [2]string{entry.Path, target})
+	}
+
+	return pairs, nil
+}
+
+// indexURI points the es uri at index, keeping its credentials and parameters
+func indexURI(uri, index string) string {
+	target, err := tool.ParseURI(uri)
+	if err != nil {
+		return uri
+	}
+
+	target.Path, target.RawPath = "/"+index, ""
+
+	return target.String()
+}
+
+func isESURI(uri string) bool {
+	target, err := tool.ParseURI(uri)
+	return err == nil && tool.ValidScheme(target.Scheme) == nil
+}
@@ -0,0 +1,161 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/loveuer/esgo2dump/internal/opt"
+	"github.com/loveuer/esgo2dump/internal/xfile"
+	"github.com/spf13/cobra"
+)
+
+func TestResolveIndices(t *testing.T) {
+	var path, user string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		user, _, _ = r.BasicAuth()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`[
+			{"index":"logs-2024.02","status":"open"},
+			{"index":"logs-2024.01","status":"open"},
+			{"index":"logs-2023.12","status":"close"},
+			{"index":".logs-internal","status":"open"}
+		]`))
+	}))
+	defer server.Close()
+
+	uri := strings.Replace(server.URL, "http://", "http://elastic:secret@", 1) + "/logs-*"
+
+	got, err := resolveIndices(context.Background(), uri)
+	if err != nil {
+		t.Fatalf("resolveIndices() error = %v", err)
+	}
+
+	if want := []string{"logs-2024.01", "logs-2024.02"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("resolveIndices() = %v, want %v", got, want)
+	}
+
+	if path != "/_cat/indices/logs-*" || user != "elastic" {
+		t.Errorf("resolveIndices() requested %s as %q", path, user)
+	}
+
+	// a hanging node times out and the next one is asked
+	hanging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { <-r.Context().Done() }))
+	defer hanging.Close()
+
+	defer func(timeout int) { opt.Timeout = timeout }(opt.Timeout)
+	opt.Timeout = 1
+
+	uri = "http://" + hanging.Listener.Addr().String() + "," + server.Listener.Addr().String() + "/logs-*"
+	if got, err = resolveIndices(context.Background(), uri); err != nil || len(got) != 2 {
+		t.Errorf("resolveIndices() after a hanging node = %v, %v", got, err)
+	}
+}
+
+func TestMultiIndex(t *testing.T) {
+	dir := t.TempDir()
+	restore := t.TempDir()
+	if err := xfile.WriteIndexList(restore, xfile.NewIndexList("data")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input, output string
+		want          bool
+	}{
+		{"http://127.0.0.1:9200/a,b", dir, true},
+		{"http://127.0.0.1:9200/logs-*", "http://127.0.0.1:9201", true},
+		{"http://127.0.0.1:9200/logs-*", "out/", true},
+		{"http://127.0.0.1:9200/logs-*", "output.json", false},
+		{"http://127.0.0.1:9200/logs-*", "http://127.0.0.1:9201/logs", false},
+		{"http://127.0.0.1:9200/logs", dir, false},
+		{restore, "http://127.0.0.1:9201", true},
+	}
+
+	for _, tt := range tests {
+		if got := MultiIndex(tt.input, tt.output); got != tt.want {
+			t.Errorf("MultiIndex(%s, %s) = %v, want %v", tt.input, tt.output, got, tt.want)
+		}
+	}
+}
+
+func TestRunMulti(t *testing.T) {
+	opt.Cfg.Args.Limit = 2
+	opt.Cfg.Args.Type = "data"
+	defer func() {
+		opt.Cfg.Args.Limit = 0
+		opt.Cfg.Args.Type = ""
+	}()
+
+	// a multi index dump directory of two indices is copied index by index
+	input := t.TempDir()
+	list := xfile.NewIndexList("data")
+	for index, docs := range map[string]int{"a": 3, "b": 2} {
+		var sb strings.Builder
+		for i := 0; i < docs; i++ {
+			fmt.Fprintf(&sb, `{"_index":%q,"_id":"%d","_source":{}}`+"\n", index, i)
+		}
+
+		if err := os.WriteFile(filepath.Join(input, index+".json"), []byte(sb.String()), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		list.Indices = append(list.Indices, xfile.IndexEntry{Index: index, Path: index + ".json"})
+	}
+
+	if err := xfile.WriteIndexList(input, list); err != nil {
+		t.Fatal(err)
+	}
+
+	output := filepath.Join(t.TempDir(), "out")
+	opt.Cfg.Args.Input, opt.Cfg.Args.Output = input, output
+	defer func() { opt.Cfg.Args.Input, opt.Cfg.Args.Output = "", "" }()
+
+	cmd := &cobra.Command{}
+	cmd.SetContext(context.Background())
+
+	if err := RunMulti(cmd); err != nil {
+		t.Fatalf("RunMulti() error = %v", err)
+	}
+
+	if opt.Cfg.Args.Input != input || opt.Cfg.Args.Output != output {
+		t.Errorf("RunMulti() left input = %s, output = %s", opt.Cfg.Args.Input, opt.Cfg.Args.Output)
+	}
+
+	got, err := xfile.ReadIndexList(output)
+	if err != nil {
+		t.Fatalf("ReadIndexList() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(got.Indices, list.Indices) {
+		t.Errorf("ReadIndexList() = %v, want %v", got.Indices, list.Indices)
+	}
+
+	for _, entry := range got.Indices {
+		f, err := os.Open(filepath.Join(output, entry.Path))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		lines := 0
+		for scanner := bufio.NewScanner(f); scanner.Scan(); lines++ {
+			if !strings.Contains(scanner.Text(), fmt.Sprintf(`"_index":%q`, entry.Index)) {
+				t.Errorf("%s holds %s", entry.Path, scanner.Text())
+			}
+		}
+		f.Close()
+
+		if want := map[string]int{"a": 3, "b": 2}[entry.Index]; lines != want {
+			t.Errorf("%s has %d docs, want %d", entry.Path, lines, want)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/dop251/goja"
+	"github.com/loveuer/esgo2dump/pkg/log"
+)
+
+// transformFunc is the function a --transform script has to define
+const transformFunc = "transform"
+
+// transformer runs the transform(doc) function of a --transform script on every doc between read and write.
+// doc is the {_id, _index, _routing, _source} wrapper, plain docs are wrapped as {_source}.
+// the function edits doc in place or returns a new one, returning null or false drops the doc.
+// goja runtimes are not goroutine safe, every reader takes one from the pool
+type transformer struct {
+	program *goja.Program
+	pool    sync.Pool
+}
+
+// transformRuntime is a runtime with the script loaded
+type transformRuntime struct {
+	vm *goja.Runtime
+	fn goja.Callable
+}
+
+// newTransformer compiles the script at path, nil when path is empty
+func newTransformer(path string) (*transformer, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read transform script: %w", err)
+	}
+
+	program, err := goja.Compile(path, string(bs), true)
+	if err != nil {
+		return nil, fmt.Errorf("compile transform script: %w", err)
+	}
+
+	t := &transformer{program: program}
+
+	// fail early on a script without transform(doc)
+	rt, err := t.runtime()
+	if err != nil {
+		return nil, err
+	}
+
+	t.pool.Put(rt)
+
+	return t, nil
+}
+
+func (t *transformer) runtime() (*transformRuntime, error) {
+	if rt, ok := t.pool.Get().(*transformRuntime); ok {
+		return rt, nil
+	}
+
+	vm := goja.New()
+	if _, err := vm.RunProgram(t.program); err != nil {
+		return nil, fmt.Errorf("run transform script: %w", err)
+	}
+
+	fn, ok := goja.AssertFunction(vm.Get(transformFunc))
+	if !ok {
+		return nil, fmt.Errorf("transform script does not define function %s(doc)", transformFunc)
+	}
+
+	return &transformRuntime{vm: vm, fn: fn}, nil
+}
+
+// apply transforms items, dropped docs are left out of the result
+func (t *transformer) apply(items []map[string]any) ([]map[string]any, error) {
+	if t == nil {
+		return items, nil
+	}
+
+	rt, err := t.runtime()
+	if err != nil {
+		return nil, err
+	}
+	defer t.pool.Put(rt)
+
+	list := make([]map[string]any, 0, len(items))
+	for _, item := range items {
+		_, wrapper := item["_source"].(map[string]any)
+
+		doc := item
+		if !wrapper {
+			doc = map[string]any{"_source": item}
+		}
+
+		result, err := rt.fn(goja.Undefined(), rt.vm.ToValue(doc))
+		if err != nil {
+			return nil, fmt.Errorf("transform doc %v: %w", doc["_id"], err)
+		}
+
+		switch {
+		case goja.IsUndefined(result):
+			// edited in place
+		case goja.IsNull(result) || result.Export() == false:
+			continue
+		default:
+			var ok bool
+			if doc, ok = result.Export().(map[string]any); !ok {
+				return nil, fmt.Errorf("transform doc %v: returned %v, want an object, null or false", doc["_id"], result)
+			}
+		}
+
+		if doc, err = unwrapTransformed(doc, wrapper); err != nil {
+			return nil, err
+		}
+
+		list = append(list, doc)
+	}
+
+	if dropped := len(items) - len(list); dropped > 0 {
+		log.Debug("Dump: transform dropped %d of %d docs", dropped, len(items))
+	}
+
+	return list, nil
+}
+
+// unwrapTransformed checks the wrapper a script gave back, a plain doc stays plain unless the script set its metadata
+func unwrapTransformed(doc map[string]any, wrapper bool) (map[string]any, error) {
+	source, ok := doc["_source"].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("transform doc %v: _source is %T, want an object", doc["_id"], doc["_source"])
+	}
+
+	// es ids are strings, scripts may compute numbers
+	if id, ok := doc["_id"]; ok && id != nil {
+		if _, ok = id.(string); !ok {
+			doc["_id"] = fmt.Sprint(id)
+		}
+	}
+
+	if !wrapper && len(doc) == 1 {
+		return source, nil
+	}
+
+	return doc, nil
+}
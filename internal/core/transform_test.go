@@ -0,0 +1,137 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/loveuer/esgo2dump/internal/opt"
+	"github.com/spf13/cobra"
+)
+
+func writeScript(t *testing.T, script string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "transform.js")
+	if err := os.WriteFile(path, []byte(script), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestTransformer_Apply(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		items  []map[string]any
+		want   []map[string]any
+	}{
+		{
+			name:   "edit in place",
+			script: `function transform(doc) { delete doc._source.email; doc._source.name = doc._source.name.toUpperCase() }`,
+			items:  []map[string]any{{"_id": "1", "_source": map[string]any{"name": "a", "email": "a@x.io"}}},
+			want:   []map[string]any{{"_id": "1", "_source": map[string]any{"name": "A"}}},
+		},
+		{
+			name:   "drop",
+			script: `function transform(doc) { return doc._source.age < 18 ? null : doc }`,
+			items: []map[string]any{
+				{"_id": "1", "_source": map[string]any{"age": float64(12)}},
+				{"_id": "2", "_source": map[string]any{"age": float64(30)}},
+			},
+			want: []map[string]any{{"_id": "2", "_source": map[string]any{"age": float64(30)}}},
+		},
+		{
+			name:   "new doc with computed id",
+			script: `function transform(doc) { return {_id: doc._source.n * 2, _source: {n: doc._source.n}} }`,
+			items:  []map[string]any{{"_id": "1", "_source": map[string]any{"n": float64(21), "other": true}}},
+			want:   []map[string]any{{"_id": "42", "_source": map[string]any{"n": int64(21)}}},
+		},
+		{
+			name:   "plain doc stays plain",
+			script: `function transform(doc) { doc._source.seen = true }`,
+			items:  []map[string]any{{"name": "a"}},
+			want:   []map[string]any{{"name": "a", "seen": true}},
+		},
+		{
+			name:   "plain doc with a new id",
+			script: `function transform(doc) { doc._id = doc._source.name }`,
+			items:  []map[string]any{{"name": "a"}},
+			want:   []map[string]any{{"_id": "a", "_source": map[string]any{"name": "a"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tf, err := newTransformer(writeScript(t, tt.script))
+			if err != nil {
+				t.Fatalf("newTransformer() error = %v", err)
+			}
+
+			got, err := tf.apply(tt.items)
+			if err != nil {
+				t.Fatalf("apply() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("apply() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransformer_Invalid(t *testing.T) {
+	for _, script := range []string{`function transform(doc {`, `var x = 1`} {
+		if _, err := newTransformer(writeScript(t, script)); err == nil {
+			t.Errorf("newTransformer(%s) should fail", script)
+		}
+	}
+
+	tf, err := newTransformer(writeScript(t, `function transform(doc) { return 1 }`))
+	if err != nil {
+		t.Fatalf("newTransformer() error = %v", err)
+	}
+
+	if _, err = tf.apply([]map[string]any{{"_source": map[string]any{}}}); err == nil {
+		t.Error("apply() returning a number should fail")
+	}
+}
+
+func TestRunData_Transform(t *testing.T) {
+	opt.Cfg.Args.Limit = 3
+	opt.Cfg.Args.Slices = 2
+	opt.Cfg.Args.Workers = 2
+	opt.Cfg.Args.Transform = writeScript(t, `function transform(doc) { if (doc._source.n % 2) return false; doc._source.half = doc._source.n / 2 }`)
+	defer func() {
+		opt.Cfg.Args.Limit = 0
+		opt.Cfg.Args.Slices = 0
+		opt.Cfg.Args.Workers = 0
+		opt.Cfg.Args.Transform = ""
+	}()
+
+	input, output := &memIO{}, &memIO{}
+	for i := 0; i < 20; i++ {
+		input.docs = append(input.docs, map[string]any{"_id": fmt.Sprint(i), "_source": map[string]any{"n": float64(i)}})
+	}
+
+	cmd := &cobra.Command{}
+	cmd.SetContext(context.Background())
+	if err := RunData(cmd, input, output); err != nil {
+		t.Fatalf("RunData() error = %v", err)
+	}
+
+	if len(output.written) != 10 {
+		t.Fatalf("RunData() wrote %d docs, want 10", len(output.written))
+	}
+
+	for _, doc := range output.written {
+		source := doc["_source"].(map[string]any)
+		if fmt.Sprint(source["half"]) != fmt.Sprint(source["n"].(float64)/2) {
+			t.Errorf("RunData() wrote %v", doc)
+		}
+	}
+}
@@ -0,0 +1,439 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/loveuer/esgo2dump/internal/opt"
+	"github.com/loveuer/esgo2dump/internal/tool"
+	"github.com/loveuer/esgo2dump/pkg/log"
+	"github.com/loveuer/esgo2dump/pkg/model"
+	"github.com/spf13/cobra"
+)
+
+const (
+	verifyCount     = "count"
+	verifyMissing   = "missing"
+	verifyExtra     = "extra"
+	verifyDifferent = "different"
+)
+
+// verify compares one query of an input with its output, --verify prints them instead of dumping
+type verify struct {
+	Input      string
+	Output     string
+	Query      string
+	InputDocs  int
+	OutputDocs int
+	// Missing, Extra and Different are only checked with --verify-ids or --verify-sample
+	Missing   string
+	Extra     string
+	Different string
+	// Unchecked lists the checks the run does not support, e.g. --verify-ids on docs without _id
+	Unchecked string
+	Result    string
+}
+
+// errNoID fails the _id diff of docs which have none, like plain docs read from a file
+var errNoID = errors.New("doc without _id, ids can not be compared")
+
+// maxVerifyIDs caps the _id of one query kept in memory by the _id diff, the input ones and the extra output ones
+var maxVerifyIDs = 1_000_000
+
+// verifyProblem is one line of the --verify-report file
+type verifyProblem struct {
+	Input      string         `json:"input"`
+	Output     string         `json:"output"`
+	Query      map[string]any `json:"query,omitempty"`
+	Problem    string         `json:"problem"`
+	ID         string         `json:"_id,omitempty"`
+	InputDocs  *int           `json:"input_docs,omitempty"`
+	OutputDocs *int           `json:"output_docs,omitempty"`
+}
+
+// RunVerify compares the docs of the input and the output of a finished dump (of every index for a multi index run),
+// per query, and writes what is missing or different into the --verify-report file, it fails on any difference
+func RunVerify(cmd *cobra.Command) error {
+	input, output := opt.Cfg.Args.Input, opt.Cfg.Args.Output
+	defer func() { opt.Cfg.Args.Input, opt.Cfg.Args.Output = input, output }()
+
+	pairs, err := indexPairs(cmd.Context(), input, output)
+	if err != nil {
+		return err
+	}
+
+	queries, err := collectQueries(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	// the output holds the docs as the hooks left them
+	hooks, err := newDocHooks()
+	if err != nil {
+		return err
+	}
+
+	var (
+		rows     = make([]*verify, 0, len(pairs)*len(queries))
+		problems []*verifyProblem
+	)
+
+	for _, pair := range pairs {
+		opt.Cfg.Args.Input, opt.Cfg.Args.Output = pair[0], pair[1]
+
+		vs, ps, err := verifyIndex(cmd.Context(), pair[0], pair[1], queries, hooks)
+		if err != nil {
+			return fmt.Errorf("verify %s: %w", tool.RedactURI(pair[0]), err)
+		}
+
+		rows, problems = append(rows, vs...), append(problems, ps...)
+	}
+
+	if len(rows) == 1 {
+		tool.TablePrinter(rows[0], cmd.OutOrStdout())
+	} else {
+		tool.TablePrinter(rows, cmd.OutOrStdout())
+	}
+
+	if len(problems) == 0 {
+		log.Info("Verify: output matches input, %d checks", len(rows))
+		return nil
+	}
+
+	if err = writeVerifyReport(opt.Cfg.Args.VerifyReport, problems); err != nil {
+		return err
+	}
+
+	return fmt.Errorf("verify found %d differences, see %s", len(problems), opt.Cfg.Args.VerifyReport)
+}
+
+func verifyIndex(ctx context.Context, in, out string, queries []map[string]any, hooks *docHooks) ([]*verify, []*verifyProblem, error) {
+	input, err := NewIO(ctx, in, model.Input)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if closer, ok := input.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	// es outputs keep their --index-rename, files are read back without being truncated
+	outType := model.Input
+	if isESURI(out) {
+		outType = model.Output
+	}
+
+	output, err := NewIO(ctx, out, outType)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if closer, ok := output.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	var (
+		rows     = make([]*verify, 0, len(queries))
+		problems []*verifyProblem
+		// left is what remains of --max for the next query, the dump shares it across the queries of an index
+		left = opt.Cfg.Args.Max
+	)
+
+	for _, query := range queries {
+		v := &verify{Input: tool.RedactURI(in), Output: tool.RedactURI(out), Query: "-"}
+		if query != nil {
+			bs, _ := json.Marshal(query)
+			v.Query = string(bs)
+		}
+
+		ps, err := verifyQuery(ctx, v, input, output, query, hooks, left)
+		if err != nil {
+			return nil, nil, fmt.Errorf("query %s: %w", v.Query, err)
+		}
+
+		left -= v.InputDocs
+
+		for _, p := range ps {
+			p.Input, p.Output, p.Query = v.Input, v.Output, query
+		}
+
+		rows, problems = append(rows, v), append(problems, ps...)
+	}
+
+	return rows, problems, nil
+}
+
+// verifyQuery fills v with the counts of both sides, and their _id diff with --verify-ids or --verify-sample.
+// the input is counted as dumped: through the hooks and capped by left, what remains of --max (unused without --max)
+func verifyQuery(ctx context.Context, v *verify, input, output model.IO[map[string]any], query map[string]any, hooks *docHooks, left int) ([]*verifyProblem, error) {
+	var (
+		err       error
+		problems  []*verifyProblem
+		unchecked []string
+	)
+
+	if v.InputDocs, err = countInput(ctx, input, query, hooks); err != nil {
+		return nil, fmt.Errorf("count input docs: %w", err)
+	}
+
+	if opt.Cfg.Args.Max > 0 {
+		v.InputDocs = tool.Min(v.InputDocs, left)
+	}
+
+	if v.OutputDocs, err = countDocs(ctx, output, query); err != nil {
+		return nil, fmt.Errorf("count output docs: %w", err)
+	}
+
+	switch {
+	// --max caps the docs read, which of them the transform drops depends on the order the readers went
+	case opt.Cfg.Args.Max > 0 && hooks.transform != nil:
+		unchecked = append(unchecked, "count (--max with --transform)")
+	case v.InputDocs != v.OutputDocs:
+		inputDocs, outputDocs := v.InputDocs, v.OutputDocs
+		problems = append(problems, &verifyProblem{Problem: verifyCount, InputDocs: &inputDocs, OutputDocs: &outputDocs})
+	}
+
+	v.Missing, v.Extra, v.Different = "-", "-", "-"
+	if opt.Cfg.Args.VerifyIDs || opt.Cfg.Args.VerifySample > 0 {
+		diff, err := diffIDs(ctx, input, output, query, hooks)
+		switch {
+		case errors.Is(err, errNoID):
+			unchecked = append(unchecked, "ids (docs without _id)")
+		case err != nil:
+			return nil, err
+		default:
+			// the docs left out by --max are not missing
+			if opt.Cfg.Args.Max > 0 {
+				unchecked = append(unchecked, "missing (--max)")
+				diff.missing = nil
+			} else {
+				v.Missing = fmt.Sprint(len(diff.missing))
+			}
+
+			v.Extra = fmt.Sprint(len(diff.extra))
+			if opt.Cfg.Args.VerifySample > 0 {
+				v.Different = fmt.Sprintf("%d of %d sampled", len(diff.different), diff.sampled)
+			}
+
+			for _, group := range []struct {
+				problem string
+				ids     []string
+			}{{verifyMissing, diff.missing}, {verifyExtra, diff.extra}, {verifyDifferent, diff.different}} {
+				for _, id := range group.ids {
+					problems = append(problems, &verifyProblem{Problem: group.problem, ID: id})
+				}
+			}
+		}
+	}
+
+	v.Unchecked = "-"
+	if len(unchecked) > 0 {
+		v.Unchecked = strings.Join(unchecked, ", ")
+	}
+
+	v.Result = "ok"
+	if len(problems) > 0 {
+		v.Result = "mismatch"
+	}
+
+	return problems, nil
+}
+
+// countInput counts the input docs of query, read through the hooks when a --transform may drop some
+func countInput(ctx context.Context, input model.IO[map[string]any], query map[string]any, hooks *docHooks) (int, error) {
+	if hooks.transform == nil {
+		return countDocs(ctx, input, query)
+	}
+
+	total := 0
+	err := readDocs(ctx, input, query, splitArg(opt.Cfg.Args.Field), splitArg(opt.Cfg.Args.Sort), func(items []map[string]any) error {
+		items, err := hooks.apply(items)
+		total += len(items)
+
+		return err
+	})
+
+	return total, err
+}
+
+// idDiff is the _id diff of one query, different holds the sampled docs whose _source changed
+type idDiff struct {
+	missing   []string
+	extra     []string
+	different []string
+	sampled   int
+}
+
+// diffIDs reads both sides through, the first --verify-sample input docs are also compared by the hash of their _source.
+// it fails above maxVerifyIDs instead of growing without bound
+func diffIDs(ctx context.Context, input, output model.IO[map[string]any], query map[string]any, hooks *docHooks) (*idDiff, error) {
+	var (
+		diff = &idDiff{}
+		// hashes of the input docs, nil when not sampled
+		ids = make(map[string][]byte)
+		// held checks the _id kept in memory against maxVerifyIDs
+		held = func() error {
+			if len(ids)+len(diff.extra) > maxVerifyIDs {
+				return fmt.Errorf("more than %d _id in one query, split it with --query to diff the ids", maxVerifyIDs)
+			}
+
+			return nil
+		}
+	)
+
+	err := readDocs(ctx, input, query, splitArg(opt.Cfg.Args.Field), splitArg(opt.Cfg.Args.Sort), func(items []map[string]any) error {
+		items, err := hooks.apply(items)
+		if err != nil {
+			return err
+		}
+
+		for _, item := range items {
+			id, err := docID(item)
+			if err != nil {
+				return err
+			}
+
+			var sum []byte
+			if len(ids) < opt.Cfg.Args.VerifySample {
+				sum = sourceHash(item)
+			}
+
+			ids[id] = sum
+			if err = held(); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read input: %w", err)
+	}
+
+	err = readDocs(ctx, output, query, nil, nil, func(items []map[string]any) error {
+		for _, item := range items {
+			id, err := docID(item)
+			if err != nil {
+				return err
+			}
+
+			sum, ok := ids[id]
+			if !ok {
+				diff.extra = append(diff.extra, id)
+				if err = held(); err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			if sum != nil {
+				diff.sampled++
+				if !bytes.Equal(sum, sourceHash(item)) {
+					diff.different = append(diff.different, id)
+				}
+			}
+
+			delete(ids, id)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read output: %w", err)
+	}
+
+	for id := range ids {
+		diff.missing = append(diff.missing, id)
+	}
+
+	sort.Strings(diff.missing)
+	sort.Strings(diff.extra)
+	sort.Strings(diff.different)
+
+	return diff, nil
+}
+
+// readDocs hands every batch of the query to fn, and clears the cursor
+func readDocs(ctx context.Context, input model.IO[map[string]any], query map[string]any, fields, sort []string, fn func([]map[string]any) error) error {
+	defer input.Cleanup()
+
+	for {
+		items, err := input.ReadData(ctx, opt.Cfg.Args.Limit, query, fields, sort)
+		if err != nil {
+			return err
+		}
+
+		if len(items) == 0 {
+			return nil
+		}
+
+		if err = fn(items); err != nil {
+			return err
+		}
+	}
+}
+
+func docID(item map[string]any) (string, error) {
+	switch id := item["_id"].(type) {
+	case nil:
+		return "", errNoID
+	case string:
+		return id, nil
+	default:
+		return fmt.Sprint(id), nil
+	}
+}
+
+// sourceHash is the sha256 of the _source json, whose keys are sorted
+func sourceHash(item map[string]any) []byte {
+	bs, _ := json.Marshal(item["_source"])
+	sum := sha256.Sum256(bs)
+
+	return sum[:]
+}
+
+// collectQueries lists the queries of the run, nil for a run without query
+func collectQueries(ctx context.Context) ([]map[string]any, error) {
+	var (
+		queries []map[string]any
+		qc      = make(chan map[string]any)
+		errc    = make(chan error, 1)
+	)
+
+	go func() {
+		errc <- sendQueries(ctx, qc)
+		close(qc)
+	}()
+
+	for query := range qc {
+		queries = append(queries, query)
+	}
+
+	return queries, <-errc
+}
+
+func writeVerifyReport(path string, problems []*verifyProblem) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create verify report: %w", err)
+	}
+	defer f.Close()
+
+	encoder := json.NewEncoder(f)
+	for _, p := range problems {
+		if err = encoder.Encode(p); err != nil {
+			return fmt.Errorf("write verify report: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,168 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/loveuer/esgo2dump/internal/opt"
+	"github.com/spf13/cobra"
+)
+
+func TestRunVerify(t *testing.T) {
+	dir := t.TempDir()
+	in, out, report := filepath.Join(dir, "in.json"), filepath.Join(dir, "out.json"), filepath.Join(dir, "report.json")
+
+	docs := func(lines ...string) []byte { return []byte(strings.Join(lines, "\n") + "\n") }
+	if err := os.WriteFile(in, docs(
+		`{"_id":"1","_source":{"name":"a"}}`,
+		`{"_id":"2","_source":{"name":"b"}}`,
+		`{"_id":"3","_source":{"name":"c"}}`,
+		`{"_id":"4","_source":{"name":"d"}}`,
+	), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	opt.Cfg.Args.Input, opt.Cfg.Args.Output = in, out
+	opt.Cfg.Args.Type = "data"
+	opt.Cfg.Args.Limit = 3
+	opt.Cfg.Args.VerifyReport = report
+	defer func() {
+		opt.Cfg.Args.Input, opt.Cfg.Args.Output = "", ""
+		opt.Cfg.Args.Type = ""
+		opt.Cfg.Args.Limit = 0
+		opt.Cfg.Args.VerifyReport = ""
+		opt.Cfg.Args.VerifyIDs = false
+		opt.Cfg.Args.VerifySample = 0
+	}()
+
+	run := func() (string, error) {
+		var buf bytes.Buffer
+		cmd := &cobra.Command{}
+		cmd.SetContext(context.Background())
+		cmd.SetOut(&buf)
+
+		err := RunVerify(cmd)
+
+		return buf.String(), err
+	}
+
+	// a faithful copy, keys in another order
+	if err := os.WriteFile(out, docs(
+		`{"_source":{"name":"a"},"_id":"1"}`,
+		`{"_id":"2","_source":{"name":"b"}}`,
+		`{"_id":"3","_source":{"name":"c"}}`,
+		`{"_id":"4","_source":{"name":"d"}}`,
+	), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	opt.Cfg.Args.VerifySample = 10
+	if printed, err := run(); err != nil || !strings.Contains(printed, "0 of 4 sampled") {
+		t.Fatalf("RunVerify() of a copy printed\n%s\nerr = %v", printed, err)
+	}
+
+	if _, err := os.Stat(report); !os.IsNotExist(err) {
+		t.Errorf("RunVerify() of a copy wrote a report, err = %v", err)
+	}
+
+	// same count: 4 is missing, 5 is extra and 2 changed
+	if err := os.WriteFile(out, docs(
+		`{"_id":"1","_source":{"name":"a"}}`,
+		`{"_id":"2","_source":{"name":"B"}}`,
+		`{"_id":"3","_source":{"name":"c"}}`,
+		`{"_id":"5","_source":{"name":"e"}}`,
+	), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	printed, err := run()
+	if err == nil {
+		t.Fatalf("RunVerify() should fail, printed\n%s", printed)
+	}
+
+	bs, err := os.ReadFile(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var problems []string
+	for _, line := range strings.Split(strings.TrimSpace(string(bs)), "\n") {
+		p := new(verifyProblem)
+		if err = json.Unmarshal([]byte(line), p); err != nil {
+			t.Fatal(err)
+		}
+
+		problems = append(problems, p.Problem+" "+p.ID)
+	}
+
+	if got, want := strings.Join(problems, ", "), "missing 4, extra 5, different 2"; got != want {
+		t.Errorf("report = %s, want %s", got, want)
+	}
+
+	// counts only, with a query the output fails
+	opt.Cfg.Args.VerifySample = 0
+	opt.Cfg.Args.Query = `{"term":{"name":"d"}}`
+	defer func() { opt.Cfg.Args.Query = "" }()
+
+	if printed, err = run(); err == nil || !strings.Contains(printed, "mismatch") {
+		t.Fatalf("RunVerify() printed\n%s\nerr = %v", printed, err)
+	}
+
+	if bs, err = os.ReadFile(report); err != nil || !strings.Contains(string(bs), `"problem":"count","input_docs":1,"output_docs":0`) {
+		t.Errorf("report = %s, err = %v", bs, err)
+	}
+
+	opt.Cfg.Args.Query = ""
+
+	// the input is counted as dumped: a transform dropping d and --max
+	if err = os.WriteFile(out, docs(
+		`{"_id":"1","_source":{"name":"a"}}`,
+		`{"_id":"2","_source":{"name":"b"}}`,
+		`{"_id":"3","_source":{"name":"c"}}`,
+	), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	opt.Cfg.Args.VerifyIDs = true
+	opt.Cfg.Args.Transform = writeScript(t, `function transform(doc) { return doc._source.name == "d" ? null : doc }`)
+	defer func() { opt.Cfg.Args.Transform = "" }()
+
+	if printed, err = run(); err != nil {
+		t.Errorf("RunVerify() with a transform printed\n%s\nerr = %v", printed, err)
+	}
+
+	opt.Cfg.Args.Transform = ""
+	opt.Cfg.Args.Max = 3
+	defer func() { opt.Cfg.Args.Max = 0 }()
+
+	if printed, err = run(); err != nil || !strings.Contains(printed, "missing (--max)") {
+		t.Errorf("RunVerify() with --max printed\n%s\nerr = %v", printed, err)
+	}
+
+	// the _id diff stops above the cap instead of holding every _id
+	opt.Cfg.Args.Max = 0
+	maxVerifyIDs = 2
+	defer func() { maxVerifyIDs = 1_000_000 }()
+
+	if printed, err = run(); err == nil || !strings.Contains(err.Error(), "more than 2 _id") {
+		t.Errorf("RunVerify() above the _id cap printed\n%s\nerr = %v", printed, err)
+	}
+
+	maxVerifyIDs = 1_000_000
+
+	// plain docs have no _id to compare, the ids are reported unchecked
+	for _, path := range []string{in, out} {
+		if err = os.WriteFile(path, docs(`{"name":"a"}`, `{"name":"b"}`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if printed, err = run(); err != nil || !strings.Contains(printed, "ids (docs without _id)") {
+		t.Errorf("RunVerify() of plain docs printed\n%s\nerr = %v", printed, err)
+	}
+}
@@ -0,0 +1,146 @@
+package core
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/loveuer/esgo2dump/internal/opt"
+	"github.com/loveuer/esgo2dump/internal/tool"
+	"github.com/loveuer/esgo2dump/pkg/log"
+	"github.com/loveuer/esgo2dump/pkg/model"
+)
+
+// nodeInfo is the answer of the root endpoint of an es node
+type nodeInfo struct {
+	Name    string `json:"name"`
+	Tagline string `json:"tagline"`
+	Version struct {
+		Number       string `json:"number"`
+		Distribution string `json:"distribution"`
+	} `json:"version"`
+}
+
+// dialect is the major version whose client talks to the node, opensearch speaks the typeless 8.x dialect
+// (the official clients refuse it), also in the compatibility mode reporting version 7.10.2
+func (n *nodeInfo) dialect() (string, error) {
+	if n.Version.Distribution == "opensearch" || strings.Contains(strings.ToLower(n.Tagline), "opensearch") {
+		return ParseESVersion("opensearch")
+	}
+
+	return ParseESVersion(n.Version.Number)
+}
+
+// ParseESVersion turns an --input-es-version/--output-es-version value (6, 7.17.1, 8, opensearch, ...) into its dialect
+func ParseESVersion(version string) (string, error) {
+	if strings.EqualFold(version, "opensearch") {
+		return "8", nil
+	}
+
+	major, _, _ := strings.Cut(version, ".")
+	switch major {
+	case "6", "7", "8":
+		return major, nil
+	}
+
+	return "", fmt.Errorf("es version not supported yet: %s", version)
+}
+
+// esVersion is the dialect of the es side of a run, from --<side>-es-version or else probed
+func esVersion(ctx context.Context, ioType model.IOType, target *url.URL, conn *tool.Conn) (string, error) {
+	version := opt.Cfg.Args.InputESVersion
+	if ioType == model.Output {
+		version = opt.Cfg.Args.OutputESVersion
+	}
+
+	if version != "" {
+		log.Debug("%s es version = %s, detection skipped", ioType, version)
+		return ParseESVersion(version)
+	}
+
+	return probeVersion(ctx, ioType, target, conn)
+}
+
+// probeTimeout is how many seconds a node has to answer the version probe before the next one is asked
+var probeTimeout = 5
+
+// probeVersion asks the nodes of target in turn for their version, until one answers.
+// a refused authentication stops there as every node shares it, other failures (and hanging nodes) try the next node
+func probeVersion(ctx context.Context, ioType model.IOType, target *url.URL, conn *tool.Conn) (string, error) {
+	var (
+		errs []error
+		hc   = newHTTPClient(conn)
+	)
+
+	for _, host := range strings.Split(target.Host, ",") {
+		endpoint := fmt.Sprintf("%s://%s", target.Scheme, host)
+
+		timeout, cancel := tool.TimeoutCtx(ctx, probeTimeout)
+		rr, err := hc.R().SetContext(timeout).Get(endpoint)
+		cancel()
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return "", context.Cause(ctx)
+			}
+
+			log.Debug("%s version probe of %s failed, err = %s", ioType, endpoint, err.Error())
+			errs = append(errs, fmt.Errorf("%s: %w%s", endpoint, err, tlsHint(ioType, err)))
+			continue
+		}
+
+		switch rr.StatusCode() {
+		case http.StatusOK:
+		case http.StatusUnauthorized:
+			return "", fmt.Errorf("%s es %s: 401 unauthorized, check the uri user:password, --%s-api-key, --%s-bearer-token, the ESGO2DUMP_%s_* env or --credentials-file",
+				ioType, endpoint, ioType, ioType, strings.ToUpper(string(ioType)))
+		case http.StatusForbidden:
+			return "", fmt.Errorf("%s es %s: 403 forbidden, the user may not read the cluster version (monitor privilege), set --%s-es-version to skip detection",
+				ioType, endpoint, ioType)
+		default:
+			errs = append(errs, fmt.Errorf("%s: status=%d, msg=%s", endpoint, rr.StatusCode(), rr.String()))
+			continue
+		}
+
+		info := new(nodeInfo)
+		if err = json.Unmarshal(rr.Body(), info); err != nil || info.Version.Number == "" {
+			errs = append(errs, fmt.Errorf("%s: not an es node answer: %.64s", endpoint, rr.String()))
+			continue
+		}
+
+		log.Debug("%s es version = %s, distribution = %s, node = %s", ioType, info.Version.Number, info.Version.Distribution, endpoint)
+
+		return info.dialect()
+	}
+
+	return "", fmt.Errorf("detect %s es version, set --%s-es-version to skip detection: %w", ioType, ioType, errors.Join(errs...))
+}
+
+// tlsHint tells what to do about a tls failure, nothing for other errors
+func tlsHint(ioType model.IOType, err error) string {
+	var (
+		unknownAuthority x509.UnknownAuthorityError
+		hostname         x509.HostnameError
+		invalid          x509.CertificateInvalidError
+		recordHeader     tls.RecordHeaderError
+	)
+
+	switch {
+	case errors.As(err, &unknownAuthority):
+		return fmt.Sprintf(" (trust its ca with --%s-ca-cert or --ca-cert, or skip verification with --%s-insecure)", ioType, ioType)
+	case errors.As(err, &hostname), errors.As(err, &invalid):
+		return fmt.Sprintf(" (fix the node certificate or skip verification with --%s-insecure)", ioType)
+	case errors.As(err, &recordHeader):
+		return " (the node does not speak tls, use http://)"
+	case strings.Contains(err.Error(), "certificate required"):
+		return fmt.Sprintf(" (the node wants a client certificate, set --%s-client-cert and --%s-client-key)", ioType, ioType)
+	}
+
+	return ""
+}
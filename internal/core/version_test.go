@@ -0,0 +1,111 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/loveuer/esgo2dump/internal/opt"
+	"github.com/loveuer/esgo2dump/internal/tool"
+	"github.com/loveuer/esgo2dump/pkg/model"
+)
+
+func TestProbeVersion(t *testing.T) {
+	answer := func(status int, body string) *httptest.Server {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(body))
+		}))
+		t.Cleanup(server.Close)
+
+		return server
+	}
+
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+
+	// hanging accepts the connection but never answers
+	hanging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { <-r.Context().Done() }))
+	t.Cleanup(hanging.Close)
+
+	defer func(timeout int) { probeTimeout = timeout }(probeTimeout)
+	probeTimeout = 1
+
+	var (
+		es6        = answer(http.StatusOK, `{"version":{"number":"6.8.23"},"tagline":"You Know, for Search"}`)
+		opensearch = answer(http.StatusOK, `{"version":{"distribution":"opensearch","number":"2.11.0"}}`)
+		// compatibility.override_main_response_version reports a 7.10.2 number
+		compat       = answer(http.StatusOK, `{"version":{"number":"7.10.2"},"tagline":"The OpenSearch Project: https://opensearch.org/"}`)
+		unauthorized = answer(http.StatusUnauthorized, `{"error":"security_exception"}`)
+		notES        = answer(http.StatusOK, `<html></html>`)
+		tlsServer    = httptest.NewTLSServer(http.NotFoundHandler())
+	)
+	defer tlsServer.Close()
+
+	host := func(server *httptest.Server) string { return server.Listener.Addr().String() }
+
+	tests := []struct {
+		name    string
+		uri     string
+		want    string
+		wantErr string
+	}{
+		{name: "next node after a dead one", uri: "http://" + host(dead) + "," + host(es6) + "/idx", want: "6"},
+		{name: "next node after a hanging one", uri: "http://" + host(hanging) + "," + host(es6) + "/idx", want: "6"},
+		{name: "opensearch", uri: "http://" + host(opensearch) + "/idx", want: "8"},
+		{name: "opensearch compatibility mode", uri: "http://" + host(compat) + "/idx", want: "8"},
+		{name: "not an es node", uri: "http://" + host(notES) + "," + host(opensearch) + "/idx", want: "8"},
+		{name: "unauthorized stops", uri: "http://" + host(unauthorized) + "," + host(es6) + "/idx", wantErr: "401 unauthorized, check the uri user:password, --input-api-key"},
+		{name: "untrusted certificate", uri: "https://" + host(tlsServer) + "/idx", wantErr: "--input-ca-cert"},
+		{name: "every node fails", uri: "http://" + host(dead) + "," + host(notES) + "/idx", wantErr: "set --input-es-version to skip detection"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, err := tool.ParseURI(tt.uri)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			conn, err := NewConn(model.Input, target)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := probeVersion(context.Background(), model.Input, target, conn)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("probeVersion() error = %v, want %q", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil || got != tt.want {
+				t.Errorf("probeVersion() = %s, %v, want %s", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestESVersion_Override(t *testing.T) {
+	probed := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { probed = true }))
+	defer server.Close()
+
+	opt.Cfg.Args.OutputESVersion = "7.17.1"
+	defer func() { opt.Cfg.Args.OutputESVersion = "" }()
+
+	target, _ := tool.ParseURI(server.URL + "/idx")
+	if got, err := esVersion(context.Background(), model.Output, target, nil); err != nil || got != "7" || probed {
+		t.Errorf("esVersion() = %s, %v, probed = %v", got, err, probed)
+	}
+
+	for version, want := range map[string]string{"6": "6", "8.11.0": "8", "OpenSearch": "8", "5.6.0": "", "x": ""} {
+		if got, err := ParseESVersion(version); got != want || (err != nil) != (want == "") {
+			t.Errorf("ParseESVersion(%s) = %s, %v, want %s", version, got, err, want)
+		}
+	}
+}
@@ -1,18 +1,68 @@
 package opt
 
 type args struct {
-	Version    bool
-	Input      string
-	Output     string
-	Limit      int
-	Max        int
-	Type       string
-	Timeout    int
-	Field      string
-	Sort       string
-	Query      string
-	QueryFile  string
-	SplitLimit int
+	Version        bool
+	Input          string `table:"uri"`
+	Output         string `table:"uri"`
+	Limit          int
+	Max            int
+	Type           string
+	Timeout        int
+	Field          string
+	Sort           string
+	Query          string
+	QueryFile      string
+	SplitLimit     int
+	PreserveIndex  bool
+	Slices         int
+	Paginate       string
+	Checkpoint     string
+	Resume         bool
+	Workers        int
+	Compress       string
+	FromPart       int
+	CreateIndex    bool
+	StaticSettings bool
+	IndexRename    []string
+	Transform      string
+	Mask           string
+	DryRun         bool
+	Verify         bool
+	VerifyIDs      bool
+	VerifySample   int
+	VerifyReport   string
+	// TLS applies to both sides, InputTLS and OutputTLS override it field by field
+	TLS       TLS
+	InputTLS  TLS
+	OutputTLS TLS
+	// InputAuth and OutputAuth take precedence over the uri user:password, the env and the credentials file
+	InputAuth       Auth
+	OutputAuth      Auth
+	CredentialsFile string
+	// InputESVersion and OutputESVersion skip the version detection of their side
+	InputESVersion  string
+	OutputESVersion string
+	// Proxy applies to both sides, InputProxy and OutputProxy override it, direct ignores the env proxy
+	Proxy       string `table:"uri"`
+	InputProxy  string `table:"uri"`
+	OutputProxy string `table:"uri"`
+	// BulkRetries bounds the retries of bulk items failed on a busy cluster, DeadLetter keeps the rejected ones
+	BulkRetries int
+	DeadLetter  string
+}
+
+// TLS is the tls of the es requests of one side
+type TLS struct {
+	CACert     string
+	ClientCert string
+	ClientKey  string
+	Insecure   bool
+}
+
+// Auth is the token credentials of one side, secrets are hidden from --debug
+type Auth struct {
+	APIKey      string `table:"secret"`
+	BearerToken string `table:"secret"`
 }
 
 type config struct {
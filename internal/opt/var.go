@@ -1,14 +1,25 @@
 package opt
 
-import (
-	"crypto/tls"
+import "time"
 
-	"github.com/go-resty/resty/v2"
+const (
+	ScrollDurationSeconds     = 10 * 60
+	DefaultSize               = 100
+	CheckpointIntervalSeconds = 10
+	// batches buffered between readers and writers per --workers
+	BatchBufferPerWorker = 2
+)
+
+const (
+	PaginateScroll      = "scroll"
+	PaginatePIT         = "pit"
+	PaginateSearchAfter = "search_after"
 )
 
 const (
-	ScrollDurationSeconds = 10 * 60
-	DefaultSize           = 100
+	CompressNone = "none"
+	CompressGzip = "gzip"
+	CompressZstd = "zstd"
 )
 
 var (
@@ -18,5 +29,7 @@ var (
 	BuffSize    = 5 * 1024 * 1024   // 5M
 	MaxBuffSize = 100 * 1024 * 1024 // 100M, default elastic_search doc max size
 
-	HttpClient = resty.New().SetTLSClientConfig(&tls.Config{InsecureSkipVerify: true})
+	// the wait before failed bulk items are sent again doubles from BulkBackoff up to BulkBackoffMax
+	BulkBackoff    = 500 * time.Millisecond
+	BulkBackoffMax = 30 * time.Second
 )
@@ -0,0 +1,121 @@
+package tool
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// Credentials authenticate es requests, the first set of APIKey (base64 id:api_key), Bearer and Username wins
+type Credentials struct {
+	Username string
+	Password string
+	APIKey   string
+	Bearer   string
+}
+
+func (c Credentials) IsZero() bool { return c == Credentials{} }
+
+// Authorization is the Authorization header value of the credentials, empty without any
+func (c Credentials) Authorization() string {
+	switch {
+	case c.APIKey != "":
+		return "ApiKey " + c.APIKey
+	case c.Bearer != "":
+		return "Bearer " + c.Bearer
+	case c.Username != "":
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(c.Username+":"+c.Password))
+	}
+
+	return ""
+}
+
+// CredentialsFile is a netrc style credentials file keyed by host, "" keys the default entry:
+//
+//	machine es1.dev:9200 login elastic password s3cret
+//	machine es2.dev api_key <base64 id:api_key>
+//	machine es3.dev bearer <token>
+//	default login reader password r3ader
+type CredentialsFile map[string]Credentials
+
+// ReadCredentialsFile parses the file at path, lines starting with # are comments
+func ReadCredentialsFile(path string) (CredentialsFile, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read credentials file: %w", err)
+	}
+
+	var (
+		tokens  []string
+		file    = make(CredentialsFile)
+		machine *string
+	)
+
+	for _, line := range strings.Split(string(bs), "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "#") {
+			tokens = append(tokens, strings.Fields(line)...)
+		}
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		if token == "default" {
+			machine = new(string)
+			file[*machine] = Credentials{}
+			continue
+		}
+
+		if i+1 == len(tokens) {
+			return nil, fmt.Errorf("credentials file %s: %s without value", path, token)
+		}
+
+		i++
+		value := tokens[i]
+
+		if token == "machine" {
+			machine = &value
+			file[*machine] = Credentials{}
+			continue
+		}
+
+		if machine == nil {
+			return nil, fmt.Errorf("credentials file %s: %s before any machine", path, token)
+		}
+
+		creds := file[*machine]
+		switch token {
+		case "login":
+			creds.Username = value
+		case "password":
+			creds.Password = value
+		case "api_key":
+			creds.APIKey = value
+		case "bearer":
+			creds.Bearer = value
+		default:
+			return nil, fmt.Errorf("credentials file %s: unknown token %s", path, token)
+		}
+
+		file[*machine] = creds
+	}
+
+	return file, nil
+}
+
+// Lookup finds the credentials of host (host:port), by host:port first, then by host name, then the default entry
+func (f CredentialsFile) Lookup(host string) (Credentials, bool) {
+	keys := []string{host}
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		keys = append(keys, name)
+	}
+
+	for _, key := range append(keys, "") {
+		if creds, ok := f[key]; ok {
+			return creds, true
+		}
+	}
+
+	return Credentials{}, false
+}
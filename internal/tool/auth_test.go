@@ -0,0 +1,54 @@
+package tool
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCredentialsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials")
+	content := `# prod cluster
+machine es1.dev:9200 login elastic password s3cret
+machine es2.dev api_key aWQ6a2V5
+machine es3.dev
+	bearer t0ken
+default login reader password r3ader
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := ReadCredentialsFile(path)
+	if err != nil {
+		t.Fatalf("ReadCredentialsFile() error = %v", err)
+	}
+
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"es1.dev:9200", "Basic ZWxhc3RpYzpzM2NyZXQ="},
+		{"es2.dev:9200", "ApiKey aWQ6a2V5"},
+		{"es3.dev", "Bearer t0ken"},
+		// another port of es1 falls back to the default entry
+		{"es1.dev:19200", "Basic cmVhZGVyOnIzYWRlcg=="},
+	}
+
+	for _, tt := range tests {
+		creds, ok := file.Lookup(tt.host)
+		if !ok || creds.Authorization() != tt.want {
+			t.Errorf("Lookup(%s) = %+v, %v, want %s", tt.host, creds, ok, tt.want)
+		}
+	}
+
+	for _, invalid := range []string{"login elastic", "machine es1.dev login", "machine es1.dev token x"} {
+		if err = os.WriteFile(path, []byte(invalid), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err = ReadCredentialsFile(path); err == nil {
+			t.Errorf("ReadCredentialsFile(%q) should fail", invalid)
+		}
+	}
+}
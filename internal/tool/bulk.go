@@ -0,0 +1,98 @@
+package tool
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/loveuer/esgo2dump/pkg/log"
+	"github.com/loveuer/esgo2dump/pkg/model"
+)
+
+// Backoff is how often and how long failed bulk items wait before they are sent again
+type Backoff struct {
+	Retries int
+	Base    time.Duration
+	Max     time.Duration
+}
+
+// Wait is the delay before retry n (from 0): Base doubled n times and capped at Max,
+// half of it jittered so the writers hitting a busy cluster together do not come back together
+func (b Backoff) Wait(n int) time.Duration {
+	wait := b.Base
+	for i := 0; i < n && wait < b.Max; i++ {
+		wait *= 2
+	}
+
+	if wait > b.Max {
+		wait = b.Max
+	}
+
+	if wait <= 1 {
+		return wait
+	}
+
+	return wait/2 + time.Duration(rand.Int63n(int64(wait/2)))
+}
+
+// RetryBulk writes items with bulk, which returns the items it failed to write. retryable failures are sent again
+// after a backoff up to b.Retries times, it returns the written count and a *model.BulkError of the items rejected for good.
+// a bulk request failing as a whole after its retries is an error, no item of it is known to be rejected
+func RetryBulk(ctx context.Context, b Backoff, items []map[string]any, bulk func(context.Context, []map[string]any) ([]*model.BulkFailure, error)) (int, error) {
+	var (
+		rejected []*model.BulkFailure
+		pending  = items
+	)
+
+	for n := 0; ; n++ {
+		failures, err := bulk(ctx, pending)
+		if err != nil {
+			return len(items) - len(pending) - len(rejected), err
+		}
+
+		var retry []*model.BulkFailure
+		for _, failure := range failures {
+			if failure.Retryable() {
+				retry = append(retry, failure)
+			} else {
+				rejected = append(rejected, failure)
+			}
+		}
+
+		if len(retry) == 0 {
+			break
+		}
+
+		if n == b.Retries {
+			for _, failure := range retry {
+				if failure.Status == 0 {
+					return len(items) - len(retry) - len(rejected), fmt.Errorf("bulk request failed after %d retries: %s", n, failure.Reason)
+				}
+			}
+
+			rejected = append(rejected, retry...)
+			break
+		}
+
+		wait := b.Wait(n)
+		log.Warn("bulk: %d of %d items failed (%s), retry %d/%d in %s", len(retry), len(pending), retry[0].Error(), n+1, b.Retries, wait)
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return len(items) - len(retry) - len(rejected), context.Cause(ctx)
+		}
+
+		pending = make([]map[string]any, 0, len(retry))
+		for _, failure := range retry {
+			pending = append(pending, failure.Item)
+		}
+	}
+
+	if len(rejected) > 0 {
+		return len(items) - len(rejected), &model.BulkError{Failures: rejected}
+	}
+
+	return len(items), nil
+}
@@ -0,0 +1,68 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/loveuer/esgo2dump/pkg/model"
+)
+
+func TestBackoff_Wait(t *testing.T) {
+	b := Backoff{Base: 100 * time.Millisecond, Max: time.Second}
+
+	for n, want := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second} {
+		if wait := b.Wait(n); wait < want/2 || wait >= want {
+			t.Errorf("Wait(%d) = %s, want in [%s, %s)", n, wait, want/2, want)
+		}
+	}
+}
+
+func TestRetryBulk(t *testing.T) {
+	b := Backoff{Retries: 2, Base: time.Millisecond, Max: time.Millisecond}
+	items := []map[string]any{{"_id": "a"}, {"_id": "busy"}, {"_id": "bad"}}
+
+	var sent [][]map[string]any
+	bulk := func(ctx context.Context, items []map[string]any) ([]*model.BulkFailure, error) {
+		sent = append(sent, items)
+
+		var failures []*model.BulkFailure
+		for _, item := range items {
+			switch item["_id"] {
+			case "busy":
+				failures = append(failures, &model.BulkFailure{Item: item, Status: 429, Type: "es_rejected_execution_exception"})
+			case "bad":
+				failures = append(failures, &model.BulkFailure{Item: item, Status: 400, Type: "mapper_parsing_exception"})
+			}
+		}
+
+		return failures, nil
+	}
+
+	// busy stays busy, it is rejected once its retries are used up
+	wrote, err := RetryBulk(context.Background(), b, items, bulk)
+
+	var bulkErr *model.BulkError
+	if !errors.As(err, &bulkErr) || len(bulkErr.Failures) != 2 || wrote != 1 {
+		t.Fatalf("RetryBulk() = %d, %v, want 1 written and 2 rejected", wrote, err)
+	}
+
+	if len(sent) != 3 || len(sent[1]) != 1 || sent[1][0]["_id"] != "busy" {
+		t.Errorf("RetryBulk() sent %v, want busy alone retried twice", sent)
+	}
+
+	// a bulk request failing as a whole is an error once its retries are used up
+	failed := func(ctx context.Context, items []map[string]any) ([]*model.BulkFailure, error) {
+		failures := make([]*model.BulkFailure, 0, len(items))
+		for _, item := range items {
+			failures = append(failures, &model.BulkFailure{Item: item, Reason: "connection refused"})
+		}
+
+		return failures, nil
+	}
+
+	if wrote, err = RetryBulk(context.Background(), b, items, failed); err == nil || errors.As(err, &bulkErr) || wrote != 0 {
+		t.Errorf("RetryBulk() of a failed request = %d, %v", wrote, err)
+	}
+}
@@ -0,0 +1,88 @@
+package tool
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// Conn is how the requests of one side of a run reach es: its tls, credentials and proxy
+type Conn struct {
+	TLS  *tls.Config
+	Auth Credentials
+	// Proxy (http, https or socks5) replaces the HTTPS_PROXY/HTTP_PROXY env, NO_PROXY hosts are reached directly
+	// with both, and Direct ignores any proxy
+	Proxy  *url.URL
+	Direct bool
+}
+
+// Transport is the http transport of the es requests, a nil Conn verifies servers with the system roots
+// and goes through the env proxy
+func (c *Conn) Transport() *http.Transport {
+	transport := &http.Transport{
+		Proxy:       c.proxy(),
+		DialContext: (&net.Dialer{Timeout: 10 * time.Second}).DialContext,
+	}
+
+	if c != nil {
+		transport.TLSClientConfig = c.TLS
+	}
+
+	return transport
+}
+
+// Credentials are the credentials of the Conn, none for a nil Conn
+func (c *Conn) Credentials() Credentials {
+	if c == nil {
+		return Credentials{}
+	}
+
+	return c.Auth
+}
+
+// proxy reads the env on every Conn, unlike http.ProxyFromEnvironment which reads it once per process
+func (c *Conn) proxy() func(*http.Request) (*url.URL, error) {
+	config := httpproxy.FromEnvironment()
+	if c != nil {
+		if c.Direct {
+			return nil
+		}
+
+		if c.Proxy != nil {
+			config.HTTPProxy, config.HTTPSProxy = c.Proxy.String(), c.Proxy.String()
+		}
+	}
+
+	proxy := config.ProxyFunc()
+
+	return func(r *http.Request) (*url.URL, error) { return proxy(r.URL) }
+}
+
+// ParseProxy checks a --proxy value, direct turns the env proxy off and gives a nil url
+func ParseProxy(proxy string) (*url.URL, bool, error) {
+	if proxy == "direct" {
+		return nil, true, nil
+	}
+
+	target, err := url.Parse(proxy)
+	if err != nil {
+		return nil, false, err
+	}
+
+	switch target.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, false, fmt.Errorf("invalid proxy scheme: %s, want http, https or socks5", target.Scheme)
+	}
+
+	if target.Host == "" {
+		return nil, false, fmt.Errorf("proxy %s without host", target.Redacted())
+	}
+
+	return target, false, nil
+}
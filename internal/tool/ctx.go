@@ -5,24 +5,17 @@ import (
 	"time"
 )
 
-func Timeout(seconds ...int) context.Context {
-	second := 30
-	if len(seconds) > 0 && seconds[0] > 0 {
-		second = seconds[0]
-	}
-
-	ctx, _ := context.WithTimeout(context.Background(), time.Duration(second)*time.Second)
-
-	return ctx
+// Timeout is a background context timing out after seconds (30 by default), call cancel once done with it
+func Timeout(seconds ...int) (context.Context, context.CancelFunc) {
+	return TimeoutCtx(context.Background(), seconds...)
 }
 
-func TimeoutCtx(ctx context.Context, seconds ...int) context.Context {
+// TimeoutCtx is ctx timing out after seconds (30 by default), call cancel once done with it
+func TimeoutCtx(ctx context.Context, seconds ...int) (context.Context, context.CancelFunc) {
 	second := 30
 	if len(seconds) > 0 && seconds[0] > 0 {
 		second = seconds[0]
 	}
 
-	timeout, _ := context.WithTimeout(ctx, time.Duration(second)*time.Second)
-
-	return timeout
+	return context.WithTimeout(ctx, time.Duration(second)*time.Second)
 }
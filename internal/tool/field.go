@@ -0,0 +1,50 @@
+package tool
+
+import "strings"
+
+// SourceFields splits --field patterns into _source includes and excludes, a '-' prefix marks an exclude
+func SourceFields(fields []string) (includes, excludes []string) {
+	for _, field := range fields {
+		if exclude, ok := strings.CutPrefix(field, "-"); ok {
+			excludes = append(excludes, exclude)
+			continue
+		}
+
+		includes = append(includes, field)
+	}
+
+	return includes, excludes
+}
+
+// WildcardMatch reports whether s matches pattern, '*' matches any run of characters (dots included) like es simple match.
+// with prefix, it reports whether some string starting with s could match instead
+func WildcardMatch(pattern, s string, prefix bool) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == '*' {
+			pattern = strings.TrimLeft(pattern, "*")
+			if pattern == "" {
+				return true
+			}
+
+			for i := 0; i <= len(s); i++ {
+				if WildcardMatch(pattern, s[i:], prefix) {
+					return true
+				}
+			}
+
+			return false
+		}
+
+		if s == "" {
+			return prefix
+		}
+
+		if pattern[0] != s[0] {
+			return false
+		}
+
+		pattern, s = pattern[1:], s[1:]
+	}
+
+	return s == ""
+}
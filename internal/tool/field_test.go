@@ -0,0 +1,47 @@
+package tool
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSourceFields(t *testing.T) {
+	includes, excludes := SourceFields([]string{"user.*", "-user.password", "title"})
+
+	if !reflect.DeepEqual(includes, []string{"user.*", "title"}) {
+		t.Errorf("SourceFields() includes = %v", includes)
+	}
+
+	if !reflect.DeepEqual(excludes, []string{"user.password"}) {
+		t.Errorf("SourceFields() excludes = %v", excludes)
+	}
+}
+
+func TestWildcardMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		s       string
+		prefix  bool
+		want    bool
+	}{
+		{"exact", "user.name", "user.name", false, true},
+		{"mismatch", "user.name", "user.age", false, false},
+		{"star suffix", "user.*", "user.name", false, true},
+		{"star spans dots", "user*", "user.address.city", false, true},
+		{"star inside", "u*r.name", "user.name", false, true},
+		{"star only", "*", "anything", false, true},
+		{"shorter text", "user.name", "user", false, false},
+		{"prefix of pattern", "user.name", "user.", true, true},
+		{"prefix through star", "*.city", "user.", true, true},
+		{"prefix mismatch", "user.name", "title.", true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := WildcardMatch(tt.pattern, tt.s, tt.prefix); got != tt.want {
+				t.Errorf("WildcardMatch(%q, %q, %v) = %v, want %v", tt.pattern, tt.s, tt.prefix, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package tool
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type renameRule struct {
+	from *regexp.Regexp
+	to   string
+}
+
+// IndexRenamer rewrites index names with from_regex=>to_template rules, the regex has to match the whole name.
+// the template expands $1, ${name} like regexp.Expand, the first matching rule wins
+type IndexRenamer []renameRule
+
+// NewIndexRenamer compiles --index-rename rules
+func NewIndexRenamer(specs []string) (IndexRenamer, error) {
+	renamer := make(IndexRenamer, 0, len(specs))
+	for _, spec := range specs {
+		from, to, ok := strings.Cut(spec, "=>")
+		if !ok || from == "" || to == "" {
+			return nil, fmt.Errorf("invalid index rename %q, want from_regex=>to_template", spec)
+		}
+
+		re, err := regexp.Compile("^(?:" + from + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid index rename %q: %w", spec, err)
+		}
+
+		renamer = append(renamer, renameRule{from: re, to: to})
+	}
+
+	return renamer, nil
+}
+
+// Rename returns the new name of index, or index itself when no rule matches
+func (r IndexRenamer) Rename(index string) string {
+	for _, rule := range r {
+		if match := rule.from.FindStringSubmatchIndex(index); match != nil {
+			return string(rule.from.ExpandString(nil, rule.to, index, match))
+		}
+	}
+
+	return index
+}
@@ -0,0 +1,34 @@
+package tool
+
+import "testing"
+
+func TestIndexRenamer(t *testing.T) {
+	renamer, err := NewIndexRenamer([]string{`prod-(.*)=>staging-$1`, `logs-(?P<day>\d{4}\.\d{2}\.\d{2})=>archive-${day}`, `.*-tmp=>tmp`})
+	if err != nil {
+		t.Fatalf("NewIndexRenamer() error = %v", err)
+	}
+
+	tests := []struct {
+		index string
+		want  string
+	}{
+		{"prod-orders", "staging-orders"},
+		{"logs-2024.01.02", "archive-2024.01.02"},
+		{"orders-tmp", "tmp"},
+		// the regex matches whole names only
+		{"old-prod-orders", "old-prod-orders"},
+		{"logs-2024.01", "logs-2024.01"},
+	}
+
+	for _, tt := range tests {
+		if got := renamer.Rename(tt.index); got != tt.want {
+			t.Errorf("Rename(%s) = %s, want %s", tt.index, got, tt.want)
+		}
+	}
+
+	for _, spec := range []string{"prod-orders", "=>staging", "prod-(=>staging"} {
+		if _, err := NewIndexRenamer([]string{spec}); err == nil {
+			t.Errorf("NewIndexRenamer(%s) should fail", spec)
+		}
+	}
+}
@@ -87,6 +87,19 @@ Start:
 				return
 			}
 
+			// table:"secret" fields are masked, table:"uri" ones lose their password and credential parameters
+			switch rv.Type().Field(i).Tag.Get("table") {
+			case "secret":
+				if !field.IsZero() {
+					w.AppendRow(table.Row{strings.TrimPrefix(p, "."), "******"})
+				}
+
+				continue
+			case "uri":
+				structPrinter(w, p, RedactURI(field.String()))
+				continue
+			}
+
 			structPrinter(w, p, field.Interface())
 		}
 	}
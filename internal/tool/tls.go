@@ -0,0 +1,44 @@
+package tool
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// NewTLSConfig builds the tls of es requests: the caCert pem bundle is trusted on top of the system roots,
+// clientCert and clientKey are presented for mutual tls, and insecure skips the server certificate verification
+func NewTLSConfig(caCert, clientCert, clientKey string, insecure bool) (*tls.Config, error) {
+	config := &tls.Config{InsecureSkipVerify: insecure}
+
+	if caCert != "" {
+		bs, err := os.ReadFile(caCert)
+		if err != nil {
+			return nil, fmt.Errorf("read ca cert: %w", err)
+		}
+
+		if config.RootCAs, err = x509.SystemCertPool(); err != nil {
+			config.RootCAs = x509.NewCertPool()
+		}
+
+		if !config.RootCAs.AppendCertsFromPEM(bs) {
+			return nil, fmt.Errorf("ca cert %s has no pem certificate", caCert)
+		}
+	}
+
+	if (clientCert == "") != (clientKey == "") {
+		return nil, fmt.Errorf("client cert and client key go together")
+	}
+
+	if clientCert != "" {
+		cert, err := tls.LoadX509KeyPair(clientCert, clientKey)
+		if err != nil {
+			return nil, fmt.Errorf("load client cert: %w", err)
+		}
+
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
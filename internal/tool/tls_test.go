@@ -0,0 +1,135 @@
+package tool
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeClientCert writes a self signed client certificate and its key as pem files
+func writeClientCert(t *testing.T, dir string) (*x509.Certificate, string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "esgo2dump"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPath, keyPath := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key")
+	if err = os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return cert, certPath, keyPath
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	clientCert, certPath, keyPath := writeClientCert(t, dir)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.TLS = &tls.Config{ClientAuth: tls.VerifyClientCertIfGiven, ClientCAs: x509.NewCertPool()}
+	server.TLS.ClientCAs.AddCert(clientCert)
+	server.StartTLS()
+	defer server.Close()
+
+	caPath := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(caPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name                            string
+		caCert, clientCert, clientKey   string
+		insecure, wantConfigErr, wantOK bool
+	}{
+		{name: "unknown authority"},
+		{name: "ca cert", caCert: caPath, wantOK: true},
+		{name: "insecure", insecure: true, wantOK: true},
+		{name: "client cert", caCert: caPath, clientCert: certPath, clientKey: keyPath, wantOK: true},
+		{name: "client cert without key", clientCert: certPath, wantConfigErr: true},
+		{name: "ca cert without pem", caCert: keyPath, wantConfigErr: true},
+		{name: "missing ca cert", caCert: filepath.Join(dir, "missing.pem"), wantConfigErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := NewTLSConfig(tt.caCert, tt.clientCert, tt.clientKey, tt.insecure)
+			if (err != nil) != tt.wantConfigErr {
+				t.Fatalf("NewTLSConfig() error = %v, want error %v", err, tt.wantConfigErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+			res, err := client.Get(server.URL)
+			if (err == nil) != tt.wantOK {
+				t.Fatalf("GET error = %v, want ok %v", err, tt.wantOK)
+			}
+
+			if err == nil {
+				res.Body.Close()
+			}
+		})
+	}
+
+	// a server requiring a client certificate refuses requests without one
+	server.TLS.ClientAuth = tls.RequireAndVerifyClientCert
+	server.CloseClientConnections()
+
+	config, err := NewTLSConfig(caPath, "", "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res, err := (&http.Client{Transport: &http.Transport{TLSClientConfig: config}}).Get(server.URL); err == nil {
+		res.Body.Close()
+		t.Errorf("GET without client cert should fail")
+	}
+}
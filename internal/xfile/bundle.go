@@ -0,0 +1,434 @@
+package xfile
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/loveuer/esgo2dump/internal/opt"
+	"github.com/loveuer/esgo2dump/pkg/log"
+	"github.com/loveuer/esgo2dump/pkg/model"
+)
+
+const (
+	bundleFormat   = "esgo2dump-bundle"
+	bundleVersion  = 1
+	bundleManifest = "manifest.json"
+	bundleMapping  = "mapping.json"
+	bundleSetting  = "settings.json"
+	bundleAliases  = "aliases.json"
+	// data is data.json[.gz|.zst], or a directory of data-N.json parts with --split-limit
+	bundleData = "data"
+)
+
+// manifest describes the files of a bundle, it is written last so a bundle without it is incomplete
+type manifest struct {
+	Format    string    `json:"format"`
+	Version   int       `json:"version"`
+	Tool      string    `json:"tool"`
+	Index     string    `json:"index"`
+	CreatedAt time.Time `json:"created_at"`
+	Mapping   string    `json:"mapping,omitempty"`
+	Setting   string    `json:"settings,omitempty"`
+	Aliases   string    `json:"aliases,omitempty"`
+	Data      string    `json:"data"`
+	Split     bool      `json:"split"`
+	Docs      int       `json:"docs"`
+}
+
+// bundle is a whole index in a directory (or .tar): manifest, mapping, settings, aliases and data.
+// a .tar is staged in a temp directory, packed on output Finalize and extracted on input open
+type bundle struct {
+	t        model.IOType
+	path     string
+	dir      string
+	manifest manifest
+	data     model.IO[map[string]any]
+	mu       sync.Mutex
+	packed   bool
+}
+
+func (b *bundle) Cleanup() {
+	b.data.Cleanup()
+}
+
+// Finalize implements model.Finalizer, it writes the manifest and packs a .tar bundle
+func (b *bundle) Finalize() error {
+	if b.t == model.Input {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.packed {
+		return nil
+	}
+
+	if err := b.writeJSON(bundleManifest, b.manifest); err != nil {
+		return fmt.Errorf("write bundle manifest: %w", err)
+	}
+
+	if isTar(b.path) {
+		if err := packTar(b.dir, b.path); err != nil {
+			return fmt.Errorf("pack bundle %s: %w", b.path, err)
+		}
+	}
+
+	b.packed = true
+
+	return nil
+}
+
+// Close removes the staging directory of a .tar bundle
+func (b *bundle) Close() error {
+	if !isTar(b.path) {
+		return nil
+	}
+
+	return os.RemoveAll(b.dir)
+}
+
+func (b *bundle) ReadData(ctx context.Context, limit int, query map[string]any, fields []string, sort []string) ([]map[string]any, error) {
+	return b.data.ReadData(ctx, limit, query, fields, sort)
+}
+
+func (b *bundle) WriteData(ctx context.Context, items []map[string]any) (int, error) {
+	wrote, err := b.data.WriteData(ctx, items)
+
+	b.mu.Lock()
+	b.manifest.Docs += wrote
+	b.mu.Unlock()
+
+	return wrote, err
+}
+
+func (b *bundle) ReadMapping(ctx context.Context) (map[string]any, error) {
+	return b.readJSON(b.manifest.Mapping, "mapping")
+}
+
+func (b *bundle) WriteMapping(ctx context.Context, mapping map[string]any) error {
+	b.manifest.Mapping = bundleMapping
+	return b.writeJSON(bundleMapping, mapping)
+}
+
+func (b *bundle) ReadSetting(ctx context.Context) (map[string]any, error) {
+	return b.readJSON(b.manifest.Setting, "settings")
+}
+
+func (b *bundle) WriteSetting(ctx context.Context, setting map[string]any) error {
+	b.manifest.Setting = bundleSetting
+	return b.writeJSON(bundleSetting, setting)
+}
+
+// ReadAliases implements model.Aliaser.
+func (b *bundle) ReadAliases(ctx context.Context) (map[string]any, error) {
+	if b.manifest.Aliases == "" {
+		return nil, nil
+	}
+
+	return b.readJSON(b.manifest.Aliases, "aliases")
+}
+
+// WriteAliases implements model.Aliaser.
+func (b *bundle) WriteAliases(ctx context.Context, aliases map[string]any) error {
+	b.manifest.Aliases = bundleAliases
+	return b.writeJSON(bundleAliases, aliases)
+}
+
+// Slices implements model.Slicer when the data is split into parts
+func (b *bundle) Slices(n int) ([]model.IO[map[string]any], error) {
+	slicer, ok := b.data.(model.Slicer[map[string]any])
+	if !ok {
+		return []model.IO[map[string]any]{b.data}, nil
+	}
+
+	return slicer.Slices(n)
+}
+
+// Position implements model.Resumer.
+func (b *bundle) Position() map[string]any {
+	if resumer, ok := b.data.(model.Resumer); ok {
+		return resumer.Position()
+	}
+
+	return nil
+}
+
+// Resume implements model.Resumer.
+func (b *bundle) Resume(ctx context.Context, position map[string]any) error {
+	resumer, ok := b.data.(model.Resumer)
+	if !ok {
+		return fmt.Errorf("bundle data is not resumable")
+	}
+
+	return resumer.Resume(ctx, position)
+}
+
+func (b *bundle) readJSON(name, what string) (map[string]any, error) {
+	if name == "" {
+		return nil, fmt.Errorf("bundle %s has no %s", b.path, what)
+	}
+
+	bs, err := os.ReadFile(filepath.Join(b.dir, name))
+	if err != nil {
+		return nil, err
+	}
+
+	m := make(map[string]any)
+	if err = json.Unmarshal(bs, &m); err != nil {
+		return nil, fmt.Errorf("decode bundle %s: %w", name, err)
+	}
+
+	return m, nil
+}
+
+func (b *bundle) writeJSON(name string, v any) error {
+	bs, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(b.dir, name), bs, 0o644)
+}
+
+func isTar(path string) bool {
+	return strings.HasSuffix(path, ".tar")
+}
+
+// IsBundle reports whether path is a bundle directory or a .tar file
+func IsBundle(path string) bool {
+	if isTar(path) {
+		return true
+	}
+
+	_, err := os.Stat(filepath.Join(path, bundleManifest))
+
+	return err == nil
+}
+
+// NewBundle opens the bundle at path, a directory or a .tar file.
+// an output bundle of index keeps its data in data.json, or in data-N.json parts with --split-limit
+func NewBundle(path, index string, t model.IOType) (model.IO[map[string]any], error) {
+	switch t {
+	case model.Input:
+		return openBundle(path)
+	case model.Output:
+		return createBundle(path, index)
+	}
+
+	return nil, fmt.Errorf("unknown type: %s", t)
+}
+
+func createBundle(path, index string) (model.IO[map[string]any], error) {
+	var (
+		err error
+		b   = &bundle{
+			t:    model.Output,
+			path: path,
+			dir:  path,
+			manifest: manifest{
+				Format:    bundleFormat,
+				Version:   bundleVersion,
+				Tool:      opt.Version,
+				Index:     index,
+				CreatedAt: time.Now(),
+				Split:     opt.Cfg.Args.SplitLimit > 0,
+			},
+		}
+	)
+
+	if isTar(path) {
+		if _, err = os.Stat(path); err == nil {
+			return nil, fmt.Errorf("file already exists: %s", path)
+		}
+
+		if b.dir, err = os.MkdirTemp("", "esgo2dump-bundle-"); err != nil {
+			return nil, err
+		}
+	} else {
+		if IsBundle(path) {
+			return nil, fmt.Errorf("bundle already exists: %s", path)
+		}
+
+		if err = os.MkdirAll(path, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create bundle directory: %w", err)
+		}
+	}
+
+	if b.manifest.Split {
+		b.manifest.Data = bundleData
+		b.data, err = NewSplitClient(filepath.Join(b.dir, bundleData), bundleData, opt.Cfg.Args.SplitLimit)
+	} else {
+		b.manifest.Data = bundleData + ".json" + codecExt(outputCodec(""))
+		b.data, err = NewClient(filepath.Join(b.dir, b.manifest.Data), model.Output)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+func openBundle(path string) (model.IO[map[string]any], error) {
+	var (
+		err error
+		bs  []byte
+		b   = &bundle{t: model.Input, path: path, dir: path}
+	)
+
+	if isTar(path) {
+		if b.dir, err = os.MkdirTemp("", "esgo2dump-bundle-"); err != nil {
+			return nil, err
+		}
+
+		if err = unpackTar(path, b.dir); err != nil {
+			os.RemoveAll(b.dir)
+			return nil, fmt.Errorf("unpack bundle %s: %w", path, err)
+		}
+	}
+
+	if bs, err = os.ReadFile(filepath.Join(b.dir, bundleManifest)); err != nil {
+		return nil, fmt.Errorf("bundle %s without %s: %w", path, bundleManifest, err)
+	}
+
+	if err = json.Unmarshal(bs, &b.manifest); err != nil {
+		return nil, fmt.Errorf("decode bundle manifest: %w", err)
+	}
+
+	if b.manifest.Format != bundleFormat || b.manifest.Version > bundleVersion {
+		return nil, fmt.Errorf("unsupported bundle format=%s, version=%d", b.manifest.Format, b.manifest.Version)
+	}
+
+	log.Debug("bundle: %s, index = %s, docs = %d, created at %s", path, b.manifest.Index, b.manifest.Docs, b.manifest.CreatedAt)
+
+	if b.manifest.Split {
+		b.data, err = NewSplitReader(filepath.Join(b.dir, b.manifest.Data))
+	} else {
+		b.data, err = NewClient(filepath.Join(b.dir, b.manifest.Data), model.Input)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// packTar writes the files of dir (and its sub directories) into a new tar at path
+func packTar(dir, path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tw := tar.NewWriter(f)
+
+	err = filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil || file == dir {
+			return err
+		}
+
+		name, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+
+		header.Name = filepath.ToSlash(name)
+		if err = tw.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		src, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+
+		_, err = io.Copy(tw, src)
+
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = tw.Close(); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+// unpackTar extracts the regular files and directories of the tar at path into dir
+func unpackTar(path, dir string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tr := tar.NewReader(f)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dir, filepath.FromSlash(header.Name))
+		if !strings.HasPrefix(target, filepath.Clean(dir)+string(os.PathSeparator)) {
+			return fmt.Errorf("invalid tar entry: %s", header.Name)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err = os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err = os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return err
+			}
+
+			if err = extractFile(tr, target); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func extractFile(r io.Reader, target string) error {
+	f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
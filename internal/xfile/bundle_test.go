@@ -0,0 +1,130 @@
+package xfile
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/loveuer/esgo2dump/internal/opt"
+	"github.com/loveuer/esgo2dump/pkg/model"
+)
+
+func TestBundle_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name       string
+		file       string
+		splitLimit int
+		compress   string
+	}{
+		{"directory", "bundle", 0, ""},
+		{"directory with split parts", "bundle", 2, opt.CompressGzip},
+		{"tar", "bundle.tar", 0, opt.CompressZstd},
+		{"tar with split parts", "bundle.tar", 3, ""},
+	}
+
+	var (
+		mapping = map[string]any{"src": map[string]any{"mappings": map[string]any{"properties": map[string]any{"id": map[string]any{"type": "keyword"}}}}}
+		setting = map[string]any{"src": map[string]any{"settings": map[string]any{"index": map[string]any{"number_of_shards": "3"}}}}
+		aliases = map[string]any{"src": map[string]any{"aliases": map[string]any{"current": map[string]any{}}}}
+		items   = []map[string]any{{"id": "1"}, {"id": "2"}, {"id": "3"}, {"id": "4"}, {"id": "5"}}
+	)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opt.Cfg.Args.SplitLimit = tt.splitLimit
+			opt.Cfg.Args.Compress = tt.compress
+			defer func() {
+				opt.Cfg.Args.SplitLimit = 0
+				opt.Cfg.Args.Compress = ""
+			}()
+
+			ctx := context.Background()
+			path := filepath.Join(t.TempDir(), tt.file)
+
+			output, err := NewBundle(path, "src", model.Output)
+			if err != nil {
+				t.Fatalf("NewBundle(output) error = %v", err)
+			}
+
+			if err = output.WriteMapping(ctx, mapping); err != nil {
+				t.Fatalf("WriteMapping() error = %v", err)
+			}
+
+			if err = output.WriteSetting(ctx, setting); err != nil {
+				t.Fatalf("WriteSetting() error = %v", err)
+			}
+
+			if err = output.(model.Aliaser).WriteAliases(ctx, aliases); err != nil {
+				t.Fatalf("WriteAliases() error = %v", err)
+			}
+
+			if _, err = output.WriteData(ctx, items); err != nil {
+				t.Fatalf("WriteData() error = %v", err)
+			}
+
+			// a dump failing before Finalize leaves no manifest, so no bundle
+			output.Cleanup()
+			if _, err = os.Stat(filepath.Join(path, bundleManifest)); !os.IsNotExist(err) {
+				t.Errorf("Cleanup() should not write the manifest, err = %v", err)
+			}
+
+			if _, err = os.Stat(path); isTar(path) && !os.IsNotExist(err) {
+				t.Errorf("Cleanup() should not pack %s, err = %v", path, err)
+			}
+
+			if err = output.(model.Finalizer).Finalize(); err != nil {
+				t.Fatalf("Finalize() error = %v", err)
+			}
+			_ = output.(*bundle).Close()
+
+			if _, err = NewBundle(path, "src", model.Output); err == nil {
+				t.Error("NewBundle(output) over an existing bundle should fail")
+			}
+
+			if !IsBundle(path) {
+				t.Fatalf("IsBundle(%s) = false", path)
+			}
+
+			input, err := NewBundle(path, "", model.Input)
+			if err != nil {
+				t.Fatalf("NewBundle(input) error = %v", err)
+			}
+			defer input.(*bundle).Close()
+
+			if got := input.(*bundle).manifest; got.Index != "src" || got.Docs != len(items) || got.Split != (tt.splitLimit > 0) {
+				t.Errorf("manifest = %+v", got)
+			}
+
+			if got, err := input.ReadMapping(ctx); err != nil || !reflect.DeepEqual(got, mapping) {
+				t.Errorf("ReadMapping() = %v, %v, want %v", got, err, mapping)
+			}
+
+			if got, err := input.ReadSetting(ctx); err != nil || !reflect.DeepEqual(got, setting) {
+				t.Errorf("ReadSetting() = %v, %v, want %v", got, err, setting)
+			}
+
+			if got, err := input.(model.Aliaser).ReadAliases(ctx); err != nil || !reflect.DeepEqual(got, aliases) {
+				t.Errorf("ReadAliases() = %v, %v, want %v", got, err, aliases)
+			}
+
+			got, err := input.ReadData(ctx, 100, nil, nil, nil)
+			if err != nil {
+				t.Fatalf("ReadData() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, items) {
+				t.Errorf("ReadData() = %v, want %v", got, items)
+			}
+
+			if isTar(path) {
+				staging := input.(*bundle).dir
+				_ = input.(*bundle).Close()
+				if _, err = os.Stat(staging); !os.IsNotExist(err) {
+					t.Errorf("Close() should remove the staging directory %s", staging)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package xfile
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/loveuer/esgo2dump/internal/opt"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// outputCodec picks the compression of an output file, --compress wins over the file extension
+func outputCodec(path string) string {
+	if opt.Cfg.Args.Compress != "" {
+		return opt.Cfg.Args.Compress
+	}
+
+	switch {
+	case strings.HasSuffix(path, ".gz"):
+		return opt.CompressGzip
+	case strings.HasSuffix(path, ".zst"):
+		return opt.CompressZstd
+	}
+
+	return opt.CompressNone
+}
+
+// codecExt is the file extension of a codec, split parts are named with it
+func codecExt(codec string) string {
+	switch codec {
+	case opt.CompressGzip:
+		return ".gz"
+	case opt.CompressZstd:
+		return ".zst"
+	}
+
+	return ""
+}
+
+// decompress sniffs the magic bytes of r, compressed input is decompressed on the fly whatever its name
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+
+	// a short (or empty) file just has no magic
+	magic, _ := br.Peek(len(zstdMagic))
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return gzip.NewReader(br)
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+
+		return zr.IOReadCloser(), nil
+	}
+
+	return br, nil
+}
+
+// compress wraps w with the codec, nil means plain output
+func compress(w io.Writer, codec string) (io.WriteCloser, error) {
+	switch codec {
+	case opt.CompressNone:
+		return nil, nil
+	case opt.CompressGzip:
+		return gzip.NewWriter(w), nil
+	case opt.CompressZstd:
+		return zstd.NewWriter(w)
+	}
+
+	return nil, fmt.Errorf("unknown compress: %s", codec)
+}
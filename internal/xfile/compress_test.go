@@ -0,0 +1,169 @@
+package xfile
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/loveuer/esgo2dump/internal/opt"
+	"github.com/loveuer/esgo2dump/internal/tool"
+	"github.com/loveuer/esgo2dump/pkg/model"
+)
+
+func TestClient_Compress(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		compress string
+		magic    []byte
+	}{
+		{"plain", "data.json", "", []byte(`{"id":0}`)},
+		{"gzip by extension", "data.json.gz", "", gzipMagic},
+		{"zstd by extension", "data.json.zst", "", zstdMagic},
+		{"zstd by flag", "data.json", opt.CompressZstd, zstdMagic},
+		{"none by flag", "data.json.gz", opt.CompressNone, []byte(`{"id":0}`)},
+	}
+
+	items := make([]map[string]any, 0, 5)
+	for i := 0; i < 5; i++ {
+		items = append(items, map[string]any{"id": i})
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opt.Cfg.Args.Compress = tt.compress
+			defer func() { opt.Cfg.Args.Compress = "" }()
+
+			path := filepath.Join(t.TempDir(), tt.file)
+
+			output, err := NewClient(path, model.Output)
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+
+			if _, err = output.WriteData(context.Background(), items); err != nil {
+				t.Fatalf("WriteData() error = %v", err)
+			}
+			output.Cleanup()
+
+			bs, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.HasPrefix(bs, tt.magic) {
+				t.Errorf("output starts with %x, want %x", bs[:tool.Min(len(bs), 8)], tt.magic)
+			}
+
+			// input is detected by content, not by name
+			renamed := filepath.Join(filepath.Dir(path), "input")
+			if err = os.Rename(path, renamed); err != nil {
+				t.Fatal(err)
+			}
+
+			input, err := NewClient(renamed, model.Input)
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+
+			got, err := input.ReadData(context.Background(), 100, nil, nil, nil)
+			if err != nil {
+				t.Fatalf("ReadData() error = %v", err)
+			}
+
+			if len(got) != len(items) {
+				t.Fatalf("ReadData() got %d items, want %d", len(got), len(items))
+			}
+
+			for i := range items {
+				if fmt.Sprint(got[i]["id"]) != fmt.Sprint(items[i]["id"]) {
+					t.Errorf("ReadData()[%d] = %v, want %v", i, got[i], items[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSplitClient_Compress(t *testing.T) {
+	opt.Cfg.Args.Compress = opt.CompressGzip
+	defer func() { opt.Cfg.Args.Compress = "" }()
+
+	tmpDir := t.TempDir()
+
+	items := make([]map[string]any, 0, 5)
+	for i := 0; i < 5; i++ {
+		items = append(items, map[string]any{"id": i})
+	}
+
+	client, err := NewSplitClient(tmpDir, "test_index", 3)
+	if err != nil {
+		t.Fatalf("Failed to create split client: %v", err)
+	}
+
+	if _, err = client.WriteData(context.Background(), items); err != nil {
+		t.Fatalf("WriteData() error = %v", err)
+	}
+	client.Cleanup()
+
+	for part, want := range [][]map[string]any{items[:3], items[3:]} {
+		path := filepath.Join(tmpDir, fmt.Sprintf("test_index-%d.json.gz", part+1))
+
+		input, err := NewClient(path, model.Input)
+		if err != nil {
+			t.Fatalf("NewClient(%s) error = %v", path, err)
+		}
+
+		got, err := input.ReadData(context.Background(), 100, nil, nil, nil)
+		if err != nil {
+			t.Fatalf("ReadData(%s) error = %v", path, err)
+		}
+
+		if len(got) != len(want) {
+			t.Errorf("part %s has %d items, want %d", path, len(got), len(want))
+		}
+	}
+
+	if err = client.(*splitClient).Resume(context.Background(), map[string]any{"file_index": 1}); err == nil {
+		t.Error("Resume() compressed split output should fail")
+	}
+}
+
+func TestSplitReader_ClosePart(t *testing.T) {
+	opt.Cfg.Args.Compress = opt.CompressZstd
+	defer func() { opt.Cfg.Args.Compress = "" }()
+
+	tmpDir := t.TempDir()
+
+	client, err := NewSplitClient(tmpDir, "test_index", 2)
+	if err != nil {
+		t.Fatalf("Failed to create split client: %v", err)
+	}
+
+	if _, err = client.WriteData(context.Background(), []map[string]any{{"id": 0}, {"id": 1}, {"id": 2}}); err != nil {
+		t.Fatalf("WriteData() error = %v", err)
+	}
+	client.Cleanup()
+
+	input, err := NewSplitReader(tmpDir)
+	if err != nil {
+		t.Fatalf("NewSplitReader() error = %v", err)
+	}
+
+	reader := input.(*splitReader)
+	if _, err = reader.ReadData(context.Background(), 1, nil, nil, nil); err != nil {
+		t.Fatalf("ReadData() error = %v", err)
+	}
+
+	// the zstd decoder of the part is closed along with its file, a closed decoder refuses to read
+	decompressed := reader.current.r
+	reader.Cleanup()
+
+	if _, err = decompressed.Read(make([]byte, 1)); !errors.Is(err, zstd.ErrDecoderClosed) {
+		t.Errorf("Cleanup() left the decompressor of the part open, read err = %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package xfile
+
+import (
+	"github.com/loveuer/esgo2dump/internal/tool"
+)
+
+// sourceFilter projects file records like es _source filtering:
+// an included object keeps its whole subtree, excludes win over includes,
+// objects only kept for a nested include are dropped when nothing inside them is left
+type sourceFilter struct {
+	includes []string
+	excludes []string
+}
+
+// newSourceFilter returns nil when there is nothing to filter
+func newSourceFilter(fields []string) *sourceFilter {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	includes, excludes := tool.SourceFields(fields)
+
+	return &sourceFilter{includes: includes, excludes: excludes}
+}
+
+// apply filters the _source of a {_id, _source} wrapper, or the whole item for a raw document
+func (f *sourceFilter) apply(item map[string]any) map[string]any {
+	if f == nil {
+		return item
+	}
+
+	if source, ok := item["_source"].(map[string]any); ok {
+		item["_source"] = f.filter(source, "", len(f.includes) == 0)
+		return item
+	}
+
+	return f.filter(item, "", len(f.includes) == 0)
+}
+
+func (f *sourceFilter) filter(m map[string]any, prefix string, included bool) map[string]any {
+	result := make(map[string]any, len(m))
+
+	for key, value := range m {
+		path := prefix + key
+
+		if f.match(f.excludes, path, false) {
+			continue
+		}
+
+		if included || f.match(f.includes, path, false) {
+			result[key] = f.filterValue(value, path, true)
+			continue
+		}
+
+		// not included itself, but an include may pick something inside
+		if !f.match(f.includes, path+".", true) {
+			continue
+		}
+
+		switch value.(type) {
+		case map[string]any, []any:
+			if v := f.filterValue(value, path, false); !empty(v) {
+				result[key] = v
+			}
+		}
+	}
+
+	return result
+}
+
+// filterValue filters objects, also inside arrays, other values are kept as is
+func (f *sourceFilter) filterValue(value any, path string, included bool) any {
+	switch v := value.(type) {
+	case map[string]any:
+		return f.filter(v, path+".", included)
+	case []any:
+		list := make([]any, 0, len(v))
+		for _, item := range v {
+			item = f.filterValue(item, path, included)
+
+			// without the include, only objects can hold a match
+			if !included {
+				if _, ok := item.(map[string]any); !ok || empty(item) {
+					continue
+				}
+			}
+
+			list = append(list, item)
+		}
+
+		return list
+	}
+
+	return value
+}
+
+func (f *sourceFilter) match(patterns []string, path string, prefix bool) bool {
+	for _, pattern := range patterns {
+		if tool.WildcardMatch(pattern, path, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func empty(value any) bool {
+	switch v := value.(type) {
+	case map[string]any:
+		return len(v) == 0
+	case []any:
+		return len(v) == 0
+	}
+
+	return false
+}
@@ -0,0 +1,78 @@
+package xfile
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/loveuer/esgo2dump/pkg/model"
+)
+
+func TestSourceFilter(t *testing.T) {
+	doc := `{"title":"t","user":{"name":"n","password":"p","address":{"city":"c","zip":"z"}},"tags":[{"k":"a","v":1},{"k":"b","v":2},"plain"]}`
+
+	tests := []struct {
+		name   string
+		fields []string
+		want   string
+	}{
+		{"no fields", nil, doc},
+		{"top level", []string{"title"}, `{"title":"t"}`},
+		{"object keeps subtree", []string{"user"}, `{"user":{"name":"n","password":"p","address":{"city":"c","zip":"z"}}}`},
+		{"dotted path", []string{"user.address.city"}, `{"user":{"address":{"city":"c"}}}`},
+		{"wildcard", []string{"user.*"}, `{"user":{"name":"n","password":"p","address":{"city":"c","zip":"z"}}}`},
+		{"wildcard spans dots", []string{"*.city"}, `{"user":{"address":{"city":"c"}}}`},
+		{"exclude only", []string{"-user", "-tags"}, `{"title":"t"}`},
+		{"exclude wins", []string{"user", "-user.password", "-user.address"}, `{"user":{"name":"n"}}`},
+		{"array of objects", []string{"tags.k"}, `{"tags":[{"k":"a"},{"k":"b"}]}`},
+		{"no match drops empty objects", []string{"user.missing"}, `{}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var item, want map[string]any
+			_ = json.Unmarshal([]byte(doc), &item)
+			_ = json.Unmarshal([]byte(tt.want), &want)
+
+			if got := newSourceFilter(tt.fields).apply(item); !reflect.DeepEqual(got, want) {
+				bs, _ := json.Marshal(got)
+				t.Errorf("apply(%v) = %s, want %s", tt.fields, bs, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_ReadData_Fields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.json")
+
+	lines := []string{
+		`{"_id":"1","_index":"idx","_source":{"name":"a","secret":"x"}}`,
+		`{"name":"b","secret":"y"}`,
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := NewClient(path, model.Input)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	got, err := client.ReadData(context.Background(), 10, nil, []string{"-secret"}, nil)
+	if err != nil {
+		t.Fatalf("ReadData() error = %v", err)
+	}
+
+	want := []map[string]any{
+		{"_id": "1", "_index": "idx", "_source": map[string]any{"name": "a"}},
+		{"name": "b"},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadData() = %v, want %v", got, want)
+	}
+}
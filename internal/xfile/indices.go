@@ -0,0 +1,89 @@
+package xfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/loveuer/esgo2dump/internal/opt"
+)
+
+const (
+	indexListFormat  = "esgo2dump-indices"
+	indexListVersion = 1
+	indexListFile    = "indices.json"
+)
+
+// IndexList describes a multi index dump directory: each index went to its own file, split directory or bundle
+type IndexList struct {
+	Format  string       `json:"format"`
+	Version int          `json:"version"`
+	Type    string       `json:"type"`
+	Indices []IndexEntry `json:"indices"`
+}
+
+// IndexEntry is where one index of a multi index dump lives, relative to the dump directory
+type IndexEntry struct {
+	Index string `json:"index"`
+	Path  string `json:"path"`
+}
+
+// NewIndexList starts the listing of a multi index dump of type t
+func NewIndexList(t string) *IndexList {
+	return &IndexList{Format: indexListFormat, Version: indexListVersion, Type: t}
+}
+
+// IndexPath names the output of index in a multi index dump directory:
+// <index>.json for data (<index>/ parts with --split-limit), <index>.mapping.json, <index>.setting.json, <index>/ bundle for all
+func IndexPath(index, t string) string {
+	switch t {
+	case "mapping":
+		return index + ".mapping.json"
+	case "setting":
+		return index + ".setting.json"
+	case "all":
+		return index
+	}
+
+	if opt.Cfg.Args.SplitLimit > 0 {
+		return index
+	}
+
+	return index + ".json" + codecExt(outputCodec(""))
+}
+
+// IsIndexList reports whether dir is a multi index dump directory
+func IsIndexList(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, indexListFile))
+	return err == nil
+}
+
+// ReadIndexList reads the listing of the multi index dump directory dir
+func ReadIndexList(dir string) (*IndexList, error) {
+	bs, err := os.ReadFile(filepath.Join(dir, indexListFile))
+	if err != nil {
+		return nil, err
+	}
+
+	list := new(IndexList)
+	if err = json.Unmarshal(bs, list); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", indexListFile, err)
+	}
+
+	if list.Format != indexListFormat || list.Version > indexListVersion {
+		return nil, fmt.Errorf("unsupported index list format=%s, version=%d", list.Format, list.Version)
+	}
+
+	return list, nil
+}
+
+// WriteIndexList (re)writes the listing of the multi index dump directory dir
+func WriteIndexList(dir string, list *IndexList) error {
+	bs, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(dir, indexListFile), bs, 0o644)
+}
@@ -0,0 +1,369 @@
+package xfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// matcher reports whether a record matches a query
+type matcher func(doc map[string]any) bool
+
+// compileQuery turns a subset of the es query dsl into a matcher for file records:
+// match_all, term, terms, range, exists, prefix, wildcard and bool (must/filter/should/must_not).
+// wrapper records are matched on their _source, fields are dotted paths and match when any value in arrays does
+func compileQuery(query map[string]any) (matcher, error) {
+	if len(query) == 0 {
+		return func(map[string]any) bool { return true }, nil
+	}
+
+	if len(query) > 1 {
+		return nil, fmt.Errorf("query must have exactly one clause, got %d", len(query))
+	}
+
+	for kind, body := range query {
+		switch kind {
+		case "match_all":
+			return func(map[string]any) bool { return true }, nil
+		case "bool":
+			return compileBool(body)
+		case "exists":
+			params, ok := body.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("invalid exists query: %v", body)
+			}
+
+			field, ok := params["field"].(string)
+			if !ok {
+				return nil, fmt.Errorf("exists query without field: %v", body)
+			}
+
+			return func(doc map[string]any) bool { return len(lookup(doc, field)) > 0 }, nil
+		case "term", "terms", "range", "prefix", "wildcard":
+			field, params, err := fieldParams(kind, body)
+			if err != nil {
+				return nil, err
+			}
+
+			test, err := compileLeaf(kind, params)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s query on %s: %w", kind, field, err)
+			}
+
+			return func(doc map[string]any) bool {
+				for _, value := range lookup(doc, field) {
+					if test(value) {
+						return true
+					}
+				}
+
+				return false
+			}, nil
+		default:
+			return nil, fmt.Errorf("query %s is unsupported for file input", kind)
+		}
+	}
+
+	return nil, nil
+}
+
+func compileBool(body any) (matcher, error) {
+	params, ok := body.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("invalid bool query: %v", body)
+	}
+
+	clauses := make(map[string][]matcher)
+	for _, occur := range []string{"must", "filter", "should", "must_not"} {
+		var list []any
+		switch v := params[occur].(type) {
+		case nil:
+			continue
+		case []any:
+			list = v
+		case map[string]any:
+			list = []any{v}
+		default:
+			return nil, fmt.Errorf("invalid bool.%s: %v", occur, v)
+		}
+
+		for _, item := range list {
+			clause, ok := item.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("invalid bool.%s clause: %v", occur, item)
+			}
+
+			m, err := compileQuery(clause)
+			if err != nil {
+				return nil, err
+			}
+
+			clauses[occur] = append(clauses[occur], m)
+		}
+	}
+
+	// like es, should is optional next to must/filter unless minimum_should_match says otherwise
+	minShould := 0
+	if len(clauses["should"]) > 0 && len(clauses["must"])+len(clauses["filter"]) == 0 {
+		minShould = 1
+	}
+
+	if v, ok := params["minimum_should_match"]; ok {
+		n, ok := number(v)
+		if !ok {
+			return nil, fmt.Errorf("minimum_should_match only supports a number: %v", v)
+		}
+
+		minShould = int(n)
+	}
+
+	return func(doc map[string]any) bool {
+		for _, m := range append(clauses["must"], clauses["filter"]...) {
+			if !m(doc) {
+				return false
+			}
+		}
+
+		for _, m := range clauses["must_not"] {
+			if m(doc) {
+				return false
+			}
+		}
+
+		matched := 0
+		for _, m := range clauses["should"] {
+			if matched >= minShould {
+				break
+			}
+
+			if m(doc) {
+				matched++
+			}
+		}
+
+		return matched >= minShould
+	}, nil
+}
+
+// fieldParams unpacks {"<field>": <params>}, ignoring the boost and _name options next to the field
+func fieldParams(kind string, body any) (string, any, error) {
+	params, ok := body.(map[string]any)
+	if !ok {
+		return "", nil, fmt.Errorf("invalid %s query: %v", kind, body)
+	}
+
+	var (
+		field string
+		value any
+	)
+
+	for key, v := range params {
+		if key == "boost" || key == "_name" {
+			continue
+		}
+
+		if field != "" {
+			return "", nil, fmt.Errorf("%s query on more than one field: %v", kind, body)
+		}
+
+		field, value = key, v
+	}
+
+	if field == "" {
+		return "", nil, fmt.Errorf("%s query without field: %v", kind, body)
+	}
+
+	return field, value, nil
+}
+
+// compileLeaf builds the test of one field value
+func compileLeaf(kind string, params any) (func(value any) bool, error) {
+	switch kind {
+	case "term":
+		want := params
+		if m, ok := params.(map[string]any); ok {
+			want = m["value"]
+		}
+
+		return func(value any) bool { return equal(value, want) }, nil
+	case "terms":
+		wants, ok := params.([]any)
+		if !ok {
+			return nil, fmt.Errorf("terms must be an array: %v", params)
+		}
+
+		return func(value any) bool {
+			for _, want := range wants {
+				if equal(value, want) {
+					return true
+				}
+			}
+
+			return false
+		}, nil
+	case "range":
+		bounds, ok := params.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("range must be an object: %v", params)
+		}
+
+		return func(value any) bool {
+			for op, bound := range bounds {
+				var ok bool
+				switch op {
+				case "gt":
+					ok = compare(value, bound) > 0
+				case "gte":
+					ok = compare(value, bound) >= 0
+				case "lt":
+					ok = compare(value, bound) < 0
+				case "lte":
+					ok = compare(value, bound) <= 0
+				default:
+					// format, time_zone, boost...
+					continue
+				}
+
+				if !ok {
+					return false
+				}
+			}
+
+			return true
+		}, nil
+	case "prefix", "wildcard":
+		var (
+			pattern         string
+			caseInsensitive bool
+		)
+
+		switch v := params.(type) {
+		case string:
+			pattern = v
+		case map[string]any:
+			pattern, _ = v["value"].(string)
+			if kind == "wildcard" && pattern == "" {
+				pattern, _ = v["wildcard"].(string)
+			}
+			caseInsensitive, _ = v["case_insensitive"].(bool)
+		}
+
+		expr := regexp.QuoteMeta(pattern)
+		if kind == "wildcard" {
+			expr = strings.NewReplacer(`\*`, ".*", `\?`, ".").Replace(expr)
+		} else {
+			expr += ".*"
+		}
+
+		if caseInsensitive {
+			expr = "(?i)" + expr
+		}
+
+		re, err := regexp.Compile("^" + expr + "$")
+		if err != nil {
+			return nil, err
+		}
+
+		return func(value any) bool {
+			s, ok := value.(string)
+			return ok && re.MatchString(s)
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown query %s", kind)
+}
+
+// lookup collects the values at a dotted path, arrays are flattened.
+// a missing <field>.keyword falls back to <field>, as keyword sub fields only exist in es
+func lookup(doc map[string]any, field string) []any {
+	if source, ok := doc["_source"].(map[string]any); ok {
+		doc = source
+	}
+
+	values := collect(doc, field)
+	if len(values) == 0 && strings.HasSuffix(field, ".keyword") {
+		values = collect(doc, strings.TrimSuffix(field, ".keyword"))
+	}
+
+	return values
+}
+
+func collect(value any, path string) []any {
+	switch v := value.(type) {
+	case []any:
+		var values []any
+		for _, item := range v {
+			values = append(values, collect(item, path)...)
+		}
+
+		return values
+	case map[string]any:
+		if path == "" {
+			return []any{v}
+		}
+
+		// a dotted key wins over nested objects
+		if item, ok := v[path]; ok {
+			return collect(item, "")
+		}
+
+		head, tail, found := strings.Cut(path, ".")
+		if !found {
+			return nil
+		}
+
+		return collect(v[head], tail)
+	case nil:
+		return nil
+	}
+
+	if path != "" {
+		return nil
+	}
+
+	return []any{value}
+}
+
+func equal(value, want any) bool {
+	if a, ok := number(value); ok {
+		b, ok := number(want)
+		return ok && a == b
+	}
+
+	return fmt.Sprint(value) == fmt.Sprint(want)
+}
+
+// compare orders numbers numerically and anything else (e.g. iso dates) as strings
+func compare(value, bound any) int {
+	if a, ok := number(value); ok {
+		if b, ok := number(bound); ok {
+			switch {
+			case a < b:
+				return -1
+			case a > b:
+				return 1
+			}
+
+			return 0
+		}
+	}
+
+	return strings.Compare(fmt.Sprint(value), fmt.Sprint(bound))
+}
+
+func number(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+
+	return 0, false
+}
@@ -0,0 +1,128 @@
+package xfile
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/loveuer/esgo2dump/pkg/model"
+)
+
+func TestCompileQuery(t *testing.T) {
+	docs := []string{
+		`{"_id":"1","_source":{"name":"alice","age":30,"tags":["a","b"],"user":{"city":"paris"},"created":"2024-01-10"}}`,
+		`{"_id":"2","_source":{"name":"bob","age":17,"tags":["b"],"user":{"city":"berlin"},"created":"2024-03-01"}}`,
+		`{"name":"carol","age":45,"user.city":"paris","email":null}`,
+	}
+
+	tests := []struct {
+		name    string
+		query   string
+		want    string
+		wantErr bool
+	}{
+		{"empty", `{}`, "alice,bob,carol", false},
+		{"match_all", `{"match_all":{}}`, "alice,bob,carol", false},
+		{"term", `{"term":{"name":"bob"}}`, "bob", false},
+		{"term value", `{"term":{"age":{"value":30}}}`, "alice", false},
+		{"term keyword", `{"term":{"name.keyword":"carol"}}`, "carol", false},
+		{"term in array", `{"term":{"tags":"a"}}`, "alice", false},
+		{"term nested and dotted key", `{"term":{"user.city":"paris"}}`, "alice,carol", false},
+		{"terms", `{"terms":{"name":["alice","carol"]}}`, "alice,carol", false},
+		{"range number", `{"range":{"age":{"gte":18,"lt":45}}}`, "alice", false},
+		{"range date", `{"range":{"created":{"gt":"2024-02-01","format":"yyyy-MM-dd"}}}`, "bob", false},
+		{"exists", `{"exists":{"field":"tags"}}`, "alice,bob", false},
+		{"exists null", `{"exists":{"field":"email"}}`, "", false},
+		{"prefix", `{"prefix":{"name":"ca"}}`, "carol", false},
+		{"prefix case insensitive", `{"prefix":{"name":{"value":"AL","case_insensitive":true}}}`, "alice", false},
+		{"wildcard", `{"wildcard":{"name":{"value":"?o*"}}}`, "bob", false},
+		{"bool must", `{"bool":{"must":[{"term":{"tags":"b"}},{"range":{"age":{"gt":20}}}]}}`, "alice", false},
+		{"bool should", `{"bool":{"should":[{"term":{"name":"bob"}},{"term":{"name":"carol"}}]}}`, "bob,carol", false},
+		{"bool should next to filter", `{"bool":{"filter":{"exists":{"field":"tags"}},"should":[{"term":{"name":"bob"}}]}}`, "alice,bob", false},
+		{"bool minimum_should_match", `{"bool":{"should":[{"term":{"tags":"a"}},{"term":{"tags":"b"}}],"minimum_should_match":2}}`, "alice", false},
+		{"bool must_not", `{"bool":{"must_not":{"terms":{"name":["alice","bob"]}}}}`, "carol", false},
+		{"unsupported", `{"match":{"name":"alice"}}`, "", true},
+		{"two clauses", `{"term":{"name":"a"},"prefix":{"name":"b"}}`, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := make(map[string]any)
+			if err := json.Unmarshal([]byte(tt.query), &query); err != nil {
+				t.Fatal(err)
+			}
+
+			match, err := compileQuery(query)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("compileQuery() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			var got []string
+			for _, line := range docs {
+				doc := make(map[string]any)
+				_ = json.Unmarshal([]byte(line), &doc)
+
+				if match(doc) {
+					got = append(got, lookup(doc, "name")[0].(string))
+				}
+			}
+
+			if strings.Join(got, ",") != tt.want {
+				t.Errorf("compileQuery(%s) matched %v, want %s", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_ReadData_Query(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.json")
+
+	var lines []string
+	for _, name := range []string{"a1", "b1", "a2", "b2", "a3"} {
+		lines = append(lines, `{"_id":"`+name+`","_source":{"name":"`+name+`"}}`)
+	}
+
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := NewClient(path, model.Input)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	// every query of a query file scans the whole input, Cleanup rewinds between them
+	for _, tt := range []struct {
+		prefix string
+		want   int
+	}{{"a", 3}, {"b", 2}} {
+		query := map[string]any{"prefix": map[string]any{"name": tt.prefix}}
+
+		total := 0
+		for {
+			items, err := client.ReadData(context.Background(), 2, query, nil, nil)
+			if err != nil {
+				t.Fatalf("ReadData() error = %v", err)
+			}
+
+			if len(items) == 0 {
+				break
+			}
+
+			total += len(items)
+		}
+
+		if total != tt.want {
+			t.Errorf("ReadData(prefix %s) got %d items, want %d", tt.prefix, total, tt.want)
+		}
+
+		client.Cleanup()
+	}
+}
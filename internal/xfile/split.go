@@ -4,33 +4,62 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"sync"
 
+	"github.com/loveuer/esgo2dump/internal/opt"
 	"github.com/loveuer/esgo2dump/pkg/log"
 	"github.com/loveuer/esgo2dump/pkg/model"
 )
 
 type splitClient struct {
-	dir          string
-	indexName    string
-	splitLimit   int
-	currentFile  *os.File
-	currentCount int
-	fileIndex    int
-	mu           sync.Mutex
+	dir           string
+	indexName     string
+	splitLimit    int
+	currentFile   *os.File
+	currentCount  int
+	currentOffset int64
+	fileIndex     int
+	mu            sync.Mutex
+	// codec compresses every part, currentZW is the compressor of the current part
+	codec     string
+	currentZW io.WriteCloser
 }
 
 func (c *splitClient) Cleanup() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	if c.currentFile != nil {
-		c.currentFile.Close()
-		c.currentFile = nil
+		c.closeFile()
 	}
 }
 
+// closeFile flushes the compressor of the current part and closes it
+func (c *splitClient) closeFile() {
+	if c.currentZW != nil {
+		if err := c.currentZW.Close(); err != nil {
+			log.Warn("failed to close compressed split file: %s", err.Error())
+		}
+		c.currentZW = nil
+	}
+
+	if err := c.currentFile.Close(); err != nil {
+		log.Warn("failed to close current split file: %s", err.Error())
+	}
+	c.currentFile = nil
+}
+
+// writer is where the current part goes, through the compressor if any
+func (c *splitClient) writer() io.Writer {
+	if c.currentZW != nil {
+		return c.currentZW
+	}
+
+	return c.currentFile
+}
+
 func (c *splitClient) ReadData(ctx context.Context, limit int, query map[string]any, fields []string, sort []string) ([]map[string]any, error) {
 	return nil, fmt.Errorf("split client does not support read")
 }
@@ -53,14 +82,11 @@ func (c *splitClient) WriteData(ctx context.Context, items []map[string]any) (in
 			return total, err
 		}
 
-		if _, err = c.currentFile.Write(bs); err != nil {
-			return total, err
-		}
-
-		if _, err = c.currentFile.WriteString("\n"); err != nil {
+		if _, err = c.writer().Write(append(bs, '\n')); err != nil {
 			return total, err
 		}
 
+		c.currentOffset += int64(len(bs)) + 1
 		c.currentCount++
 		total++
 	}
@@ -71,30 +97,94 @@ func (c *splitClient) WriteData(ctx context.Context, items []map[string]any) (in
 func (c *splitClient) rotateFile() error {
 	// Close current file if exists
 	if c.currentFile != nil {
-		if err := c.currentFile.Close(); err != nil {
-			log.Warn("failed to close current split file: %s", err.Error())
-		}
-		c.currentFile = nil
+		c.closeFile()
 		c.currentCount = 0
 	}
 
 	// Create new file
 	c.fileIndex++
-	filename := fmt.Sprintf("%s-%d.json", c.indexName, c.fileIndex)
-	filepath := filepath.Join(c.dir, filename)
+	filepath := c.partPath(c.fileIndex)
 
 	f, err := os.OpenFile(filepath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
 	if err != nil {
 		return fmt.Errorf("failed to create split file %s: %w", filepath, err)
 	}
 
+	if c.currentZW, err = compress(f, c.codec); err != nil {
+		f.Close()
+		return err
+	}
+
 	c.currentFile = f
 	c.currentCount = 0
+	c.currentOffset = 0
 
 	log.Debug("created new split file: %s", filepath)
 	return nil
 }
 
+func (c *splitClient) partPath(index int) string {
+	return filepath.Join(c.dir, fmt.Sprintf("%s-%d.json%s", c.indexName, index, codecExt(c.codec)))
+}
+
+// Position implements model.Resumer.
+func (c *splitClient) Position() map[string]any {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// a compressed part cannot be cut at an offset
+	if c.codec != opt.CompressNone {
+		return nil
+	}
+
+	return map[string]any{"file_index": c.fileIndex, "count": c.currentCount, "offset": c.currentOffset}
+}
+
+// Resume implements model.Resumer.
+// the part file at the position is truncated to its checkpointed size and writing continues in it
+func (c *splitClient) Resume(ctx context.Context, position map[string]any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.codec != opt.CompressNone {
+		return fmt.Errorf("resume compressed split output is unsupported")
+	}
+
+	if c.currentFile != nil {
+		c.closeFile()
+	}
+
+	c.fileIndex = positionInt(position, "file_index")
+	c.currentCount = positionInt(position, "count")
+	c.currentOffset = int64(positionInt(position, "offset"))
+
+	if c.fileIndex == 0 {
+		return nil
+	}
+
+	filepath := c.partPath(c.fileIndex)
+
+	f, err := os.OpenFile(filepath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open split file %s: %w", filepath, err)
+	}
+
+	if err = f.Truncate(c.currentOffset); err != nil {
+		f.Close()
+		return err
+	}
+
+	if _, err = f.Seek(c.currentOffset, io.SeekStart); err != nil {
+		f.Close()
+		return err
+	}
+
+	c.currentFile = f
+
+	log.Debug("resumed split file: %s, count = %d, offset = %d", filepath, c.currentCount, c.currentOffset)
+	return nil
+}
+
 func (c *splitClient) ReadMapping(ctx context.Context) (map[string]any, error) {
 	return nil, fmt.Errorf("split client does not support read mapping")
 }
@@ -126,6 +216,7 @@ func NewSplitClient(dir string, indexName string, splitLimit int) (model.IO[map[
 		indexName:  indexName,
 		splitLimit: splitLimit,
 		fileIndex:  0,
+		codec:      outputCodec(dir),
 	}
 
 	return c, nil
@@ -0,0 +1,236 @@
+package xfile
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/loveuer/esgo2dump/internal/opt"
+	"github.com/loveuer/esgo2dump/pkg/log"
+	"github.com/loveuer/esgo2dump/pkg/model"
+)
+
+// partName matches the parts written by splitClient: <index>-<N>.json, optionally compressed
+var partName = regexp.MustCompile(`^(.+)-(\d+)\.json(\.gz|\.zst)?$`)
+
+type splitPart struct {
+	path   string
+	number int
+}
+
+// splitReader reads a split output directory back as one stream, part after part in <index>-N.json order
+type splitReader struct {
+	dir   string
+	parts []splitPart
+	mu    sync.Mutex
+	// next is the index in parts to open, current the open part
+	next    int
+	current *client
+}
+
+func (r *splitReader) Cleanup() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// like a cleared scroll, the next query reads from the first part
+	r.closePart()
+	r.next = 0
+}
+
+func (r *splitReader) closePart() {
+	if r.current == nil {
+		return
+	}
+
+	r.current.closeReader()
+
+	if err := r.current.f.Close(); err != nil {
+		log.Warn("failed to close split part: %s", err.Error())
+	}
+
+	r.current = nil
+}
+
+func (r *splitReader) openPart() error {
+	part := r.parts[r.next]
+
+	in, err := NewClient(part.path, model.Input)
+	if err != nil {
+		return fmt.Errorf("failed to open split part %s: %w", part.path, err)
+	}
+
+	r.current = in.(*client)
+	r.next++
+
+	log.Debug("reading split part: %s", part.path)
+
+	return nil
+}
+
+func (r *splitReader) ReadData(ctx context.Context, limit int, query map[string]any, fields []string, sort []string) ([]map[string]any, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	list := make([]map[string]any, 0, limit)
+
+	for len(list) < limit {
+		if r.current == nil {
+			if r.next >= len(r.parts) {
+				break
+			}
+
+			if err := r.openPart(); err != nil {
+				return nil, err
+			}
+		}
+
+		items, err := r.current.ReadData(ctx, limit-len(list), query, fields, sort)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(items) == 0 {
+			r.closePart()
+			continue
+		}
+
+		list = append(list, items...)
+	}
+
+	return list, nil
+}
+
+func (r *splitReader) WriteData(ctx context.Context, items []map[string]any) (int, error) {
+	return 0, fmt.Errorf("split directory input does not support write")
+}
+
+func (r *splitReader) ReadMapping(ctx context.Context) (map[string]any, error) {
+	return nil, fmt.Errorf("split directory input does not support read mapping")
+}
+
+func (r *splitReader) WriteMapping(ctx context.Context, mapping map[string]any) error {
+	return fmt.Errorf("split directory input does not support write mapping")
+}
+
+func (r *splitReader) ReadSetting(ctx context.Context) (map[string]any, error) {
+	return nil, fmt.Errorf("split directory input does not support read setting")
+}
+
+func (r *splitReader) WriteSetting(ctx context.Context, setting map[string]any) error {
+	return fmt.Errorf("split directory input does not support write setting")
+}
+
+// Slices implements model.Slicer, parts are dealt round-robin so n readers restore them concurrently
+func (r *splitReader) Slices(n int) ([]model.IO[map[string]any], error) {
+	if n < 1 {
+		return nil, fmt.Errorf("invalid slices(> 0): %d", n)
+	}
+
+	slices := make([]model.IO[map[string]any], 0, n)
+	for i := 0; i < n; i++ {
+		slice := &splitReader{dir: r.dir}
+		for j := i; j < len(r.parts); j += n {
+			slice.parts = append(slice.parts, r.parts[j])
+		}
+
+		slices = append(slices, slice)
+	}
+
+	return slices, nil
+}
+
+// Position implements model.Resumer.
+func (r *splitReader) Position() map[string]any {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.current == nil {
+		// between parts: the next one has not been touched yet
+		return map[string]any{"part": r.next, "records": 0}
+	}
+
+	return map[string]any{"part": r.next - 1, "records": r.current.read}
+}
+
+// Resume implements model.Resumer.
+// it reopens the part at the position and skips the records read in it before
+func (r *splitReader) Resume(ctx context.Context, position map[string]any) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.closePart()
+	r.next = positionInt(position, "part")
+
+	records := positionInt(position, "records")
+	if records == 0 || r.next >= len(r.parts) {
+		return nil
+	}
+
+	if err := r.openPart(); err != nil {
+		return err
+	}
+
+	return r.current.Resume(ctx, map[string]any{"records": records})
+}
+
+// listParts finds the split parts of dir in part number order, starting at part number from
+func listParts(dir string, from int) ([]splitPart, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		parts   []splitPart
+		indexes = make(map[string]bool)
+	)
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		matches := partName.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			continue
+		}
+
+		number, _ := strconv.Atoi(matches[2])
+		indexes[matches[1]] = true
+
+		if number < from {
+			continue
+		}
+
+		parts = append(parts, splitPart{path: filepath.Join(dir, entry.Name()), number: number})
+	}
+
+	if len(indexes) > 1 {
+		return nil, fmt.Errorf("split directory %s holds parts of %d indexes", dir, len(indexes))
+	}
+
+	sort.Slice(parts, func(i, j int) bool { return parts[i].number < parts[j].number })
+
+	return parts, nil
+}
+
+// NewSplitReader reads the <index>-N.json parts written with --split-limit from dir, starting at --from-part
+func NewSplitReader(dir string) (model.IO[map[string]any], error) {
+	parts, err := listParts(dir, opt.Cfg.Args.FromPart)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("no split parts (<index>-N.json) found in %s", dir)
+	}
+
+	log.Debug("split input: %s, parts = %d, first = %s", dir, len(parts), parts[0].path)
+
+	return &splitReader{dir: dir, parts: parts}, nil
+}
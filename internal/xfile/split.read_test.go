@@ -0,0 +1,167 @@
+package xfile
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/loveuer/esgo2dump/internal/opt"
+	"github.com/loveuer/esgo2dump/pkg/model"
+)
+
+// writeSplitDir writes n docs with ids 0..n-1 as split parts of limit docs
+func writeSplitDir(t *testing.T, n, limit int) string {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	client, err := NewSplitClient(dir, "test_index", limit)
+	if err != nil {
+		t.Fatalf("Failed to create split client: %v", err)
+	}
+	defer client.Cleanup()
+
+	items := make([]map[string]any, 0, n)
+	for i := 0; i < n; i++ {
+		items = append(items, map[string]any{"id": i})
+	}
+
+	if _, err = client.WriteData(context.Background(), items); err != nil {
+		t.Fatalf("WriteData() error = %v", err)
+	}
+
+	return dir
+}
+
+// readIDs drains an input and returns the ids it read in order
+func readIDs(t *testing.T, input model.IO[map[string]any], limit int) []string {
+	t.Helper()
+
+	var ids []string
+	for {
+		items, err := input.ReadData(context.Background(), limit, nil, nil, nil)
+		if err != nil {
+			t.Fatalf("ReadData() error = %v", err)
+		}
+
+		if len(items) == 0 {
+			return ids
+		}
+
+		for _, item := range items {
+			ids = append(ids, fmt.Sprint(item["id"]))
+		}
+	}
+}
+
+func TestSplitReader_ReadData(t *testing.T) {
+	// 12 parts, numeric order puts test_index-10.json after test_index-9.json
+	dir := writeSplitDir(t, 23, 2)
+
+	tests := []struct {
+		name     string
+		fromPart int
+		first    string
+		want     int
+	}{
+		{"all parts", 0, "0", 23},
+		{"from part", 10, "18", 5},
+		{"from part past the end", 13, "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opt.Cfg.Args.FromPart = tt.fromPart
+			defer func() { opt.Cfg.Args.FromPart = 0 }()
+
+			input, err := NewSplitReader(dir)
+			if tt.want == 0 {
+				if err == nil {
+					t.Error("NewSplitReader() without parts should fail")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("NewSplitReader() error = %v", err)
+			}
+
+			ids := readIDs(t, input, 3)
+			if len(ids) != tt.want {
+				t.Fatalf("ReadData() got %d docs, want %d", len(ids), tt.want)
+			}
+
+			for i, id := range ids {
+				if id != fmt.Sprint(i+23-tt.want) {
+					t.Fatalf("ReadData() got %v, want ids in order from %s", ids, tt.first)
+				}
+			}
+
+			input.Cleanup()
+			if again := readIDs(t, input, 5); len(again) != tt.want {
+				t.Errorf("ReadData() after Cleanup got %d docs, want %d", len(again), tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitReader_Slices(t *testing.T) {
+	dir := writeSplitDir(t, 10, 2)
+
+	input, err := NewSplitReader(dir)
+	if err != nil {
+		t.Fatalf("NewSplitReader() error = %v", err)
+	}
+
+	slices, err := input.(model.Slicer[map[string]any]).Slices(2)
+	if err != nil {
+		t.Fatalf("Slices() error = %v", err)
+	}
+
+	seen := make(map[string]bool)
+	for _, slice := range slices {
+		for _, id := range readIDs(t, slice, 3) {
+			if seen[id] {
+				t.Errorf("doc %s read by two slices", id)
+			}
+			seen[id] = true
+		}
+	}
+
+	if len(seen) != 10 {
+		t.Errorf("slices read %d docs, want 10", len(seen))
+	}
+}
+
+func TestSplitReader_Resume(t *testing.T) {
+	dir := writeSplitDir(t, 9, 3)
+
+	input, err := NewSplitReader(dir)
+	if err != nil {
+		t.Fatalf("NewSplitReader() error = %v", err)
+	}
+
+	if _, err = input.ReadData(context.Background(), 4, nil, nil, nil); err != nil {
+		t.Fatalf("ReadData() error = %v", err)
+	}
+
+	// positions come back from the checkpoint file as json numbers
+	bs, _ := json.Marshal(input.(model.Resumer).Position())
+	position := make(map[string]any)
+	_ = json.Unmarshal(bs, &position)
+
+	resumed, err := NewSplitReader(dir)
+	if err != nil {
+		t.Fatalf("NewSplitReader() error = %v", err)
+	}
+
+	if err = resumed.(model.Resumer).Resume(context.Background(), position); err != nil {
+		t.Fatalf("Resume() error = %v", err)
+	}
+
+	ids := readIDs(t, resumed, 2)
+	if fmt.Sprint(ids) != "[4 5 6 7 8]" {
+		t.Errorf("ReadData() after Resume(%v) = %v, want [4 5 6 7 8]", position, ids)
+	}
+}
@@ -208,3 +208,52 @@ func TestSplitClient_ReadData(t *testing.T) {
 		t.Error("ReadData() should return error for split client")
 	}
 }
+
+func TestSplitClient_Resume(t *testing.T) {
+	tmpDir := t.TempDir()
+	indexName := "test_index"
+
+	items := make([]map[string]any, 0, 5)
+	for i := 0; i < 5; i++ {
+		items = append(items, map[string]any{"id": i})
+	}
+
+	client, err := NewSplitClient(tmpDir, indexName, 3)
+	if err != nil {
+		t.Fatalf("Failed to create split client: %v", err)
+	}
+
+	if _, err = client.WriteData(context.Background(), items[:4]); err != nil {
+		t.Fatalf("WriteData() error = %v", err)
+	}
+
+	position := client.(*splitClient).Position()
+
+	// an interrupted run wrote past the checkpoint
+	if _, err = client.WriteData(context.Background(), []map[string]any{{"id": "lost"}}); err != nil {
+		t.Fatalf("WriteData() error = %v", err)
+	}
+	client.Cleanup()
+
+	// positions come back from the checkpoint file as json numbers
+	bs, _ := json.Marshal(position)
+	position = make(map[string]any)
+	_ = json.Unmarshal(bs, &position)
+
+	resumed, err := NewSplitClient(tmpDir, indexName, 3)
+	if err != nil {
+		t.Fatalf("Failed to create split client: %v", err)
+	}
+
+	if err = resumed.(*splitClient).Resume(context.Background(), position); err != nil {
+		t.Fatalf("Resume() error = %v", err)
+	}
+
+	if _, err = resumed.WriteData(context.Background(), items[4:]); err != nil {
+		t.Fatalf("WriteData() error = %v", err)
+	}
+	resumed.Cleanup()
+
+	verifyFileContent(t, filepath.Join(tmpDir, "test_index-1.json"), items[:3])
+	verifyFileContent(t, filepath.Join(tmpDir, "test_index-2.json"), items[3:])
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sync"
 
 	"github.com/loveuer/esgo2dump/internal/opt"
 	"github.com/loveuer/esgo2dump/pkg/log"
@@ -14,23 +15,101 @@ import (
 )
 
 type client struct {
+	t       model.IOType
 	info    os.FileInfo
 	f       *os.File
 	scanner *bufio.Scanner
+	mu      sync.Mutex
+	// r is the (decompressed) input, zw compresses the output, nil for plain files, see compress.go
+	r  io.Reader
+	zw io.WriteCloser
+	// records read (input) and bytes written (output), see Position
+	read   int
+	offset int64
 }
 
-func (c *client) Cleanup() {}
+func (c *client) Cleanup() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-func (c *client) ReadData(ctx context.Context, limit int, query map[string]any, fields []string, sort []string) ([]map[string]any, error) {
-	if len(query) != 0 {
-		return nil, fmt.Errorf("file with query is unsupported")
+	if c.t == model.Input {
+		// like a cleared scroll, the next query reads from the start
+		if err := c.rewind(); err != nil {
+			log.Warn("rewind input failed, err = %s", err.Error())
+		}
+
+		return
+	}
+
+	if err := c.closeWriter(); err != nil {
+		log.Warn("close compressed output failed, err = %s", err.Error())
+	}
+}
+
+func (c *client) rewind() error {
+	if c.read == 0 {
+		return nil
+	}
+
+	if _, err := c.f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	c.closeReader()
+
+	r, err := decompress(c.f)
+	if err != nil {
+		return err
+	}
+
+	c.r, c.scanner, c.read = r, newScanner(r), 0
+
+	return nil
+}
+
+// closeReader releases the decompressor of the input (zstd runs its own goroutines), the file stays open
+func (c *client) closeReader() {
+	if closer, ok := c.r.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Warn("close decompressed input failed, err = %s", err.Error())
+		}
+	}
+}
+
+// writer is where output goes, through the compressor if any
+func (c *client) writer() io.Writer {
+	if c.zw != nil {
+		return c.zw
+	}
+
+	return c.f
+}
+
+// closeWriter flushes the compressed stream, later writes would start a broken stream so it is closed only once
+func (c *client) closeWriter() error {
+	if c.zw == nil {
+		return nil
 	}
 
+	defer func() { c.zw = nil }()
+
+	return c.zw.Close()
+}
+
+func (c *client) ReadData(ctx context.Context, limit int, query map[string]any, fields []string, sort []string) ([]map[string]any, error) {
 	if len(sort) != 0 {
 		return nil, fmt.Errorf("file with sort is unsupported")
 	}
 
-	list := make([]map[string]any, 0, limit)
+	match, err := compileQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		list   = make([]map[string]any, 0, limit)
+		filter = newSourceFilter(fields)
+	)
 
 	for c.scanner.Scan() {
 		line := c.scanner.Bytes()
@@ -40,11 +119,14 @@ func (c *client) ReadData(ctx context.Context, limit int, query map[string]any,
 			return nil, err
 		}
 
-		if len(fields) > 0 {
-			// todo: pick fields
+		// read counts every record scanned, Resume skips lines
+		c.read++
+
+		if !match(item) {
+			continue
 		}
 
-		list = append(list, item)
+		list = append(list, filter.apply(item))
 
 		if len(list) >= limit {
 			return list, nil
@@ -55,6 +137,9 @@ func (c *client) ReadData(ctx context.Context, limit int, query map[string]any,
 }
 
 func (c *client) WriteData(ctx context.Context, items []map[string]any) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	total := 0
 	for _, item := range items {
 		bs, err := json.Marshal(item)
@@ -62,18 +147,86 @@ func (c *client) WriteData(ctx context.Context, items []map[string]any) (int, er
 			return total, err
 		}
 
-		if _, err = c.f.Write(bs); err != nil {
+		if _, err = c.writer().Write(append(bs, '\n')); err != nil {
 			return total, err
 		}
 
+		c.offset += int64(len(bs)) + 1
 		total++
+	}
 
-		if _, err = c.f.WriteString("\n"); err != nil {
-			return total, err
+	return total, nil
+}
+
+// Position implements model.Resumer.
+func (c *client) Position() map[string]any {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.t == model.Input {
+		return map[string]any{"records": c.read}
+	}
+
+	// a compressed stream cannot be cut at an offset
+	if c.zw != nil {
+		return nil
+	}
+
+	return map[string]any{"offset": c.offset}
+}
+
+// Resume implements model.Resumer.
+// input skips the records already read, output drops everything written after the position
+func (c *client) Resume(ctx context.Context, position map[string]any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.t == model.Input {
+		records := positionInt(position, "records")
+		for c.read < records && c.scanner.Scan() {
+			c.read++
+		}
+
+		if c.read < records {
+			return fmt.Errorf("resume input: want skip %d records, but file has %d", records, c.read)
 		}
+
+		return c.scanner.Err()
 	}
 
-	return total, nil
+	if c.zw != nil {
+		return fmt.Errorf("resume compressed output is unsupported")
+	}
+
+	offset := int64(positionInt(position, "offset"))
+	if err := c.f.Truncate(offset); err != nil {
+		return err
+	}
+
+	if _, err := c.f.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	c.offset = offset
+
+	return nil
+}
+
+// positionInt reads an int from a position, which may come back from a json checkpoint as float64 or json.Number
+func positionInt(position map[string]any, key string) int {
+	switch v := position[key].(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	case json.Number:
+		n, _ := v.Int64()
+		return int(n)
+	default:
+		return 0
+	}
 }
 
 func (c *client) ReadMapping(ctx context.Context) (map[string]any, error) {
@@ -82,7 +235,7 @@ func (c *client) ReadMapping(ctx context.Context) (map[string]any, error) {
 		bs  []byte
 	)
 
-	if bs, err = io.ReadAll(c.f); err != nil {
+	if bs, err = io.ReadAll(c.r); err != nil {
 		return nil, err
 	}
 
@@ -101,9 +254,11 @@ func (c *client) WriteMapping(ctx context.Context, mapping map[string]any) error
 		return err
 	}
 
-	_, err = c.f.Write(bs)
+	if _, err = c.writer().Write(bs); err != nil {
+		return err
+	}
 
-	return err
+	return c.closeWriter()
 }
 
 func (c *client) ReadSetting(ctx context.Context) (map[string]any, error) {
@@ -112,7 +267,7 @@ func (c *client) ReadSetting(ctx context.Context) (map[string]any, error) {
 		bs  []byte
 	)
 
-	if bs, err = io.ReadAll(c.f); err != nil {
+	if bs, err = io.ReadAll(c.r); err != nil {
 		return nil, err
 	}
 
@@ -131,9 +286,11 @@ func (c *client) WriteSetting(ctx context.Context, setting map[string]any) error
 		return err
 	}
 
-	_, err = c.f.Write(bs)
+	if _, err = c.writer().Write(bs); err != nil {
+		return err
+	}
 
-	return err
+	return c.closeWriter()
 }
 
 func NewClient(path string, t model.IOType) (model.IO[map[string]any], error) {
@@ -155,6 +312,15 @@ func NewClient(path string, t model.IOType) (model.IO[map[string]any], error) {
 			return nil, err
 		}
 	case model.Output:
+		if opt.Cfg.Args.Resume {
+			// keep what the interrupted run wrote, Resume truncates it back to the checkpoint
+			if f, err = os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644); err != nil {
+				return nil, err
+			}
+
+			break
+		}
+
 		if info, err = os.Stat(path); err == nil {
 			return nil, fmt.Errorf("file already exists: %s", path)
 		}
@@ -170,11 +336,28 @@ func NewClient(path string, t model.IOType) (model.IO[map[string]any], error) {
 		return nil, fmt.Errorf("unknown type: %s", t)
 	}
 
-	c := &client{f: f, info: info}
+	c := &client{t: t, f: f, info: info}
+
+	switch t {
+	case model.Input:
+		if c.r, err = decompress(f); err != nil {
+			return nil, fmt.Errorf("decompress input %s: %w", path, err)
+		}
+	case model.Output:
+		if c.zw, err = compress(f, outputCodec(path)); err != nil {
+			return nil, err
+		}
+	}
+
+	c.scanner = newScanner(c.r)
+
+	return c, nil
+}
+
+func newScanner(r io.Reader) *bufio.Scanner {
 	buf := make([]byte, opt.BuffSize)
-	scanner := bufio.NewScanner(c.f)
+	scanner := bufio.NewScanner(r)
 	scanner.Buffer(buf, opt.MaxBuffSize)
-	c.scanner = scanner
 
-	return c, nil
+	return scanner
 }
@@ -15,14 +15,15 @@ func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
 	defer cancel()
 
-	go func() {
-		<-ctx.Done()
-		log.Warn("Process interrupted")
-		os.Exit(1)
-	}()
+	// an interrupt cancels ctx, the running dump stops on it and returns.
+	// the signals are released then, so a second one kills a run stuck in a request or a retry wait
+	context.AfterFunc(ctx, func() {
+		log.Warn("Process interrupted, interrupt again to kill it")
+		cancel()
+	})
 
 	if err := cmd.Run(ctx); err != nil {
 		log.Error(err.Error())
-		return
+		os.Exit(1)
 	}
 }
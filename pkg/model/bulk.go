@@ -0,0 +1,80 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// BulkFailure is an item an es output failed to write, Status 0 means its whole bulk request failed
+type BulkFailure struct {
+	Item   map[string]any
+	Status int
+	Type   string
+	Reason string
+}
+
+// Retryable tells a busy or unavailable cluster (429, 503, es_rejected_execution) from a rejection
+// of the doc itself like a mapping error, which fails again however often it is sent
+func (f *BulkFailure) Retryable() bool {
+	switch f.Status {
+	case 0, 429, 503:
+		return true
+	}
+
+	return strings.Contains(f.Type, "es_rejected_execution")
+}
+
+func (f *BulkFailure) Error() string {
+	if f.Type == "" {
+		return fmt.Sprintf("status=%d, %s", f.Status, f.Reason)
+	}
+
+	return fmt.Sprintf("status=%d, %s: %s", f.Status, f.Type, f.Reason)
+}
+
+// BulkError is returned by WriteData, along with the written count, when some items failed for good,
+// every item not in Failures was written
+type BulkError struct {
+	Failures []*BulkFailure
+}
+
+func (e *BulkError) Error() string {
+	return fmt.Sprintf("%d docs rejected, first: %s", len(e.Failures), e.Failures[0].Error())
+}
+
+// BulkResponse is the answer of a _bulk request, its items follow the ones of the request
+type BulkResponse struct {
+	Errors bool                          `json:"errors"`
+	Items  []map[string]BulkResponseItem `json:"items"`
+}
+
+// BulkResponseItem is the result of one bulk action, keyed by the action in BulkResponse.Items
+type BulkResponseItem struct {
+	Index      string `json:"_index"`
+	DocumentID string `json:"_id"`
+	Status     int    `json:"status"`
+	Error      struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error"`
+}
+
+// Failures are the items of the request the response rejects, an item without a response item
+// is not known to be written and fails like a whole request (Status 0)
+func (r *BulkResponse) Failures(items []map[string]any) []*BulkFailure {
+	failed := make([]*BulkFailure, 0)
+	for i, item := range items {
+		if i >= len(r.Items) {
+			failed = append(failed, &BulkFailure{Item: item, Reason: fmt.Sprintf("no bulk response item, got %d of %d", len(r.Items), len(items))})
+			continue
+		}
+
+		for _, v := range r.Items[i] {
+			if v.Status > 299 {
+				failed = append(failed, &BulkFailure{Item: item, Status: v.Status, Type: v.Error.Type, Reason: v.Error.Reason})
+			}
+		}
+	}
+
+	return failed
+}
@@ -3,10 +3,30 @@ package model
 type ESSource[T any] struct {
 	DocId   string `json:"_id"`
 	Index   string `json:"_index"`
+	Type    string `json:"_type,omitempty"`
+	Routing string `json:"_routing,omitempty"`
 	Content T      `json:"_source"`
 	Sort    []any  `json:"sort"`
 }
 
+// ToESSource converts an item passed through model.IO into an ESSource,
+// items holding a _source object are the wrappers produced by es readers,
+// any other item is treated as a plain document without metadata
+func ToESSource(item map[string]any) *ESSource[map[string]any] {
+	source, ok := item["_source"].(map[string]any)
+	if !ok {
+		return &ESSource[map[string]any]{Content: item}
+	}
+
+	doc := &ESSource[map[string]any]{Content: source}
+	doc.DocId, _ = item["_id"].(string)
+	doc.Index, _ = item["_index"].(string)
+	doc.Type, _ = item["_type"].(string)
+	doc.Routing, _ = item["_routing"].(string)
+
+	return doc
+}
+
 type ESResponseV6[T any] struct {
 	ScrollId string `json:"_scroll_id"`
 	Took     int    `json:"took"`
@@ -26,6 +46,7 @@ type ESResponseV6[T any] struct {
 
 type ESResponseV7[T any] struct {
 	ScrollId string `json:"_scroll_id"`
+	PitId    string `json:"pit_id"`
 	Took     int    `json:"took"`
 	TimedOut bool   `json:"timed_out"`
 	Shards   struct {
@@ -0,0 +1,297 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ErrIndexExists is returned by IndexCreator.CreateIndex when the index is already there
+var ErrIndexExists = errors.New("index already exists")
+
+// IsIndexExists reports whether an es error response body is a create index conflict
+func IsIndexExists(status int, body string) bool {
+	return status == 400 && strings.Contains(body, "resource_already_exists_exception")
+}
+
+// NonPortableSettings are index settings es assigns to an index itself, creating an index with them fails
+var NonPortableSettings = []string{"uuid", "creation_date", "version", "provided_name"}
+
+// unwrap returns body[key] of an es response keyed by index name ({<index>: {<key>: ...}}), or of the body itself
+func unwrap(body map[string]any, key string) map[string]any {
+	if inner, ok := body[key].(map[string]any); ok {
+		return inner
+	}
+
+	for _, v := range body {
+		if m, ok := v.(map[string]any); ok {
+			if inner, ok := m[key].(map[string]any); ok {
+				return inner
+			}
+		}
+	}
+
+	return nil
+}
+
+// UnwrapMapping returns the mappings of a ReadMapping result
+func UnwrapMapping(mapping map[string]any) map[string]any {
+	return unwrap(mapping, "mappings")
+}
+
+// Typeless returns the single type body of a 6.x style typed mapping, other mappings are returned as is
+func Typeless(mappings map[string]any) map[string]any {
+	if _, ok := mappings["properties"]; ok || len(mappings) != 1 {
+		return mappings
+	}
+
+	for _, v := range mappings {
+		if inner, ok := v.(map[string]any); ok {
+			if _, ok = inner["properties"]; ok {
+				return inner
+			}
+		}
+	}
+
+	return mappings
+}
+
+// UnwrapAliases returns the aliases of a ReadAliases result
+func UnwrapAliases(aliases map[string]any) map[string]any {
+	return unwrap(aliases, "aliases")
+}
+
+// PortableSettings returns the settings of a ReadSetting result without NonPortableSettings,
+// nested ({index: {uuid: ..}}) and flat ({index.uuid: ..}) settings are both handled
+func PortableSettings(setting map[string]any) map[string]any {
+	settings := unwrap(setting, "settings")
+	if settings == nil {
+		return nil
+	}
+
+	result := make(map[string]any, len(settings))
+	for key, value := range settings {
+		if strings.HasPrefix(key, "index.") && isNonPortable(strings.TrimPrefix(key, "index.")) {
+			continue
+		}
+
+		if key == "index" {
+			if index, ok := value.(map[string]any); ok {
+				portable := make(map[string]any, len(index))
+				for k, v := range index {
+					if !isNonPortable(k) {
+						portable[k] = v
+					}
+				}
+
+				value = portable
+			}
+		}
+
+		result[key] = value
+	}
+
+	return result
+}
+
+func isNonPortable(key string) bool {
+	return hasSetting(NonPortableSettings, key)
+}
+
+// IndexBody builds a create index body from ReadSetting and ReadMapping results
+func IndexBody(setting, mapping map[string]any) map[string]any {
+	body := make(map[string]any, 2)
+
+	if settings := PortableSettings(setting); len(settings) > 0 {
+		body["settings"] = settings
+	}
+
+	if mappings := UnwrapMapping(mapping); len(mappings) > 0 {
+		body["mappings"] = mappings
+	}
+
+	return body
+}
+
+// AliasActions builds the _aliases actions adding the aliases of a ReadAliases result to index
+func AliasActions(index string, aliases map[string]any) []map[string]any {
+	var actions []map[string]any
+
+	for name, value := range UnwrapAliases(aliases) {
+		add := map[string]any{"index": index, "alias": name}
+		if props, ok := value.(map[string]any); ok {
+			for k, v := range props {
+				add[k] = v
+			}
+		}
+
+		actions = append(actions, map[string]any{"add": add})
+	}
+
+	return actions
+}
+
+var (
+	// CreationOnlySettings can only be set when an index is created
+	CreationOnlySettings = []string{"number_of_shards", "number_of_routing_shards", "routing_partition_size", "soft_deletes.enabled", "sort"}
+	// StaticSettings can only be updated on a closed index
+	StaticSettings = []string{"codec", "analysis", "similarity", "store.type", "shard.check_on_startup", "load_fixed_bitset_filters_eagerly", "soft_deletes.retention_lease.period"}
+)
+
+// UpdatableSettings splits the portable settings of a ReadSetting result for an update settings request:
+// dynamic ones, and static ones which need a closed index. settings which can only be set on creation are dropped.
+// both come back as {index: {<flat.key>: value}}, nil when empty
+func UpdatableSettings(setting map[string]any) (dynamic, static map[string]any) {
+	var (
+		flat        = make(map[string]any)
+		dynamicFlat = make(map[string]any)
+		staticFlat  = make(map[string]any)
+		wrap        = func(m map[string]any) map[string]any {
+			if len(m) == 0 {
+				return nil
+			}
+
+			return map[string]any{"index": m}
+		}
+	)
+
+	flatten(PortableSettings(setting), "", flat)
+
+	for key, value := range flat {
+		key = strings.TrimPrefix(key, "index.")
+
+		switch {
+		case hasSetting(CreationOnlySettings, key):
+		case hasSetting(StaticSettings, key):
+			staticFlat[key] = value
+		default:
+			dynamicFlat[key] = value
+		}
+	}
+
+	return wrap(dynamicFlat), wrap(staticFlat)
+}
+
+// ApplySettings writes the UpdatableSettings of setting to an index with put.
+// static ones are only applied when withStatic, between op("close") and op("open"), and come back as skipped otherwise.
+// the index is reopened even when put fails, a closed index can not take data
+func ApplySettings(setting map[string]any, withStatic bool, put func(map[string]any) error, op func(string) error) (skipped map[string]any, err error) {
+	dynamic, static := UpdatableSettings(setting)
+
+	if static != nil && !withStatic {
+		skipped, static = static, nil
+	}
+
+	if static == nil {
+		if dynamic == nil {
+			return skipped, nil
+		}
+
+		return skipped, put(dynamic)
+	}
+
+	if idx, ok := dynamic["index"].(map[string]any); ok {
+		for key, value := range idx {
+			static["index"].(map[string]any)[key] = value
+		}
+	}
+
+	if err = op("close"); err != nil {
+		return nil, err
+	}
+
+	err = put(static)
+
+	if oerr := op("open"); oerr != nil {
+		return nil, errors.Join(err, oerr)
+	}
+
+	return nil, err
+}
+
+func flatten(m map[string]any, prefix string, flat map[string]any) {
+	for key, value := range m {
+		if inner, ok := value.(map[string]any); ok {
+			flatten(inner, prefix+key+".", flat)
+			continue
+		}
+
+		flat[prefix+key] = value
+	}
+}
+
+func hasSetting(names []string, key string) bool {
+	for _, name := range names {
+		if key == name || strings.HasPrefix(key, name+".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MappingFields flattens the properties of a ReadMapping result into dotted field paths with their types,
+// objects without a type are "object", typed (es6) mappings are unwrapped
+func MappingFields(mapping map[string]any) map[string]string {
+	fields := make(map[string]string)
+
+	mappings := UnwrapMapping(mapping)
+	if mappings == nil {
+		mappings = mapping
+	}
+
+	if _, ok := mappings["properties"]; !ok && len(mappings) == 1 {
+		for _, typed := range mappings {
+			if m, ok := typed.(map[string]any); ok {
+				if _, ok = m["properties"]; ok {
+					mappings = m
+				}
+			}
+		}
+	}
+
+	collectFields(mappings, "", fields)
+
+	return fields
+}
+
+func collectFields(mapping map[string]any, prefix string, fields map[string]string) {
+	properties, _ := mapping["properties"].(map[string]any)
+	for name, value := range properties {
+		property, ok := value.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		kind, _ := property["type"].(string)
+		if kind == "" {
+			kind = "object"
+		}
+
+		fields[prefix+name] = kind
+		collectFields(property, prefix+name+".", fields)
+	}
+}
+
+// CatIndexStat sums the docs.count and pri.store.size columns of a _cat/indices?format=json&bytes=b response
+func CatIndexStat(body io.Reader) (*IndexStat, error) {
+	var rows []map[string]any
+	if err := json.NewDecoder(body).Decode(&rows); err != nil {
+		return nil, fmt.Errorf("decode _cat/indices: %w", err)
+	}
+
+	stat := &IndexStat{Exists: len(rows) > 0}
+	for _, row := range rows {
+		// closed indices have no stats
+		docs, _ := strconv.Atoi(fmt.Sprint(row["docs.count"]))
+		bytes, _ := strconv.ParseInt(fmt.Sprint(row["pri.store.size"]), 10, 64)
+
+		stat.Docs += docs
+		stat.Bytes += bytes
+	}
+
+	return stat, nil
+}
@@ -18,3 +18,51 @@ type IO[T any] interface {
 	ReadSetting(ctx context.Context) (map[string]any, error)
 	WriteSetting(ctx context.Context, setting map[string]any) error
 }
+
+// Slicer is implemented by inputs which can split one read into n independent cursors,
+// every returned IO reads a disjoint part of the data and must be cleaned up on its own
+type Slicer[T any] interface {
+	Slices(n int) ([]IO[T], error)
+}
+
+// Resumer is implemented by IOs whose read or write position can be checkpointed,
+// Resume moves a fresh IO back to a position returned by Position
+type Resumer interface {
+	Position() map[string]any
+	Resume(ctx context.Context, position map[string]any) error
+}
+
+// Finalizer is implemented by outputs which complete their result once the whole dump succeeded,
+// Cleanup only releases their resources so a failed dump leaves an incomplete result behind
+type Finalizer interface {
+	Finalize() error
+}
+
+// IndexCreator is implemented by outputs which can create their index with settings and mapping in one go,
+// setting and mapping are ReadSetting and ReadMapping results of the input
+type IndexCreator interface {
+	CreateIndex(ctx context.Context, setting, mapping map[string]any) error
+}
+
+// Aliaser is implemented by IOs which carry index aliases, ReadAliases returns {<index>: {aliases: {...}}} like es
+type Aliaser interface {
+	ReadAliases(ctx context.Context) (map[string]any, error)
+	WriteAliases(ctx context.Context, aliases map[string]any) error
+}
+
+// Counter is implemented by IOs which count the docs matching a query without reading them
+type Counter interface {
+	Count(ctx context.Context, query map[string]any) (int, error)
+}
+
+// IndexStat is the state of an es index, Bytes is its primary store size
+type IndexStat struct {
+	Exists bool
+	Docs   int
+	Bytes  int64
+}
+
+// IndexStater is implemented by IOs backed by an es index
+type IndexStater interface {
+	IndexStat(ctx context.Context) (*IndexStat, error)
+}
@@ -2,37 +2,44 @@ package es6
 
 import (
 	"context"
-	"crypto/tls"
 	"fmt"
-	"net"
 	"net/http"
 	"net/url"
-	"time"
+	"strings"
 
 	elastic "github.com/elastic/go-elasticsearch/v6"
 	"github.com/elastic/go-elasticsearch/v6/esapi"
 	"github.com/loveuer/esgo2dump/internal/tool"
+	"github.com/samber/lo"
 )
 
-func NewClient(ctx context.Context, url *url.URL) (*elastic.Client, error) {
+func NewClient(ctx context.Context, url *url.URL, conn *tool.Conn) (*elastic.Client, error) {
 	var (
-		err         error
-		urlUsername string
-		urlPassword string
-		client      *elastic.Client
-		errCh       = make(chan error)
-		cliCh       = make(chan *elastic.Client)
-		address     = fmt.Sprintf("%s://%s", url.Scheme, url.Host)
+		err       error
+		client    *elastic.Client
+		errCh     = make(chan error)
+		cliCh     = make(chan *elastic.Client)
+		endpoints = lo.Map(
+			strings.Split(url.Host, ","),
+			func(item string, index int) string {
+				return fmt.Sprintf("%s://%s", url.Scheme, item)
+			},
+		)
 	)
 
-	if url.User != nil {
-		urlUsername = url.User.Username()
-		if p, ok := url.User.Password(); ok {
-			urlPassword = p
-		}
+	creds := conn.Credentials()
+	if creds.IsZero() && url.User != nil {
+		creds.Username = url.User.Username()
+		creds.Password, _ = url.User.Password()
+	}
+
+	// a bearer token has no es6 client field, the Authorization header covers every kind of credentials
+	header := http.Header{}
+	if authorization := creds.Authorization(); authorization != "" {
+		header.Set("Authorization", authorization)
 	}
 
-	ncFunc := func(endpoints []string, username, password string) {
+	ncFunc := func(endpoints []string, header http.Header) {
 		var (
 			err      error
 			cli      *elastic.Client
@@ -42,16 +49,11 @@ func NewClient(ctx context.Context, url *url.URL) (*elastic.Client, error) {
 		if cli, err = elastic.NewClient(
 			elastic.Config{
 				Addresses:     endpoints,
-				Username:      username,
-				Password:      password,
-				CACert:        nil,
+				Header:        header,
 				RetryOnStatus: []int{429},
 				MaxRetries:    3,
 				RetryBackoff:  nil,
-				Transport: &http.Transport{
-					TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-					DialContext:     (&net.Dialer{Timeout: 10 * time.Second}).DialContext,
-				},
+				Transport:     conn.Transport(),
 			},
 		); err != nil {
 			errCh <- err
@@ -64,7 +66,7 @@ func NewClient(ctx context.Context, url *url.URL) (*elastic.Client, error) {
 		}
 
 		if infoResp.StatusCode != 200 {
-			err = fmt.Errorf("info es7 status=%d", infoResp.StatusCode)
+			err = fmt.Errorf("info es6 status=%d", infoResp.StatusCode)
 			errCh <- err
 			return
 		}
@@ -72,12 +74,13 @@ func NewClient(ctx context.Context, url *url.URL) (*elastic.Client, error) {
 		cliCh <- cli
 	}
 
-	go ncFunc([]string{address}, urlUsername, urlPassword)
-	timeout := tool.TimeoutCtx(ctx, 10)
+	go ncFunc(endpoints, header)
+	timeout, cancel := tool.TimeoutCtx(ctx, 10)
+	defer cancel()
 
 	select {
 	case <-timeout.Done():
-		return nil, fmt.Errorf("dial es=%s err=%v", address, context.DeadlineExceeded)
+		return nil, fmt.Errorf("dial es=%s err=%v", strings.Join(endpoints, ","), context.DeadlineExceeded)
 	case client = <-cliCh:
 		return client, nil
 	case err = <-errCh:
@@ -0,0 +1,173 @@
+package es6
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/elastic/go-elasticsearch/v6/esapi"
+	"github.com/loveuer/esgo2dump/internal/opt"
+	"github.com/loveuer/esgo2dump/internal/tool"
+	"github.com/loveuer/esgo2dump/pkg/model"
+)
+
+// CreateIndex implements model.IndexCreator, non-portable settings are dropped and an existing index is model.ErrIndexExists.
+// typeless mappings (dumped from es7) are created with include_type_name=false
+func (s *streamer) CreateIndex(ctx context.Context, setting, mapping map[string]any) error {
+	body := model.IndexBody(setting, mapping)
+
+	bs, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	timeout, cancel := tool.TimeoutCtx(ctx, opt.Timeout)
+	defer cancel()
+
+	qs := []func(*esapi.IndicesCreateRequest){
+		s.client.Indices.Create.WithContext(timeout),
+		s.client.Indices.Create.WithBody(bytes.NewReader(bs)),
+	}
+
+	if mappings, ok := body["mappings"].(map[string]any); ok {
+		if _, typeless := mappings["properties"]; typeless {
+			qs = append(qs, s.client.Indices.Create.WithIncludeTypeName(false))
+		}
+	}
+
+	result, err := s.client.Indices.Create(s.index, qs...)
+	if err != nil {
+		return err
+	}
+
+	if model.IsIndexExists(result.StatusCode, result.String()) {
+		return fmt.Errorf("%w: %s", model.ErrIndexExists, s.index)
+	}
+
+	if result.StatusCode != 200 {
+		return fmt.Errorf("status=%d, msg=%s", result.StatusCode, result.String())
+	}
+
+	return nil
+}
+
+// ReadAliases implements model.Aliaser.
+func (s *streamer) ReadAliases(ctx context.Context) (map[string]any, error) {
+	timeout, cancel := tool.TimeoutCtx(ctx, opt.Timeout)
+	defer cancel()
+
+	r, err := s.client.Indices.GetAlias(
+		s.client.Indices.GetAlias.WithContext(timeout),
+		s.client.Indices.GetAlias.WithIndex(s.index),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.StatusCode != 200 {
+		return nil, fmt.Errorf("status=%d, msg=%s", r.StatusCode, r.String())
+	}
+
+	m := make(map[string]any)
+	if err = json.NewDecoder(r.Body).Decode(&m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// WriteAliases implements model.Aliaser, the aliases are added to s.index
+func (s *streamer) WriteAliases(ctx context.Context, aliases map[string]any) error {
+	actions := model.AliasActions(s.index, aliases)
+	if len(actions) == 0 {
+		return nil
+	}
+
+	bs, err := json.Marshal(map[string]any{"actions": actions})
+	if err != nil {
+		return err
+	}
+
+	timeout, cancel := tool.TimeoutCtx(ctx, opt.Timeout)
+	defer cancel()
+
+	result, err := s.client.Indices.UpdateAliases(
+		bytes.NewReader(bs),
+		s.client.Indices.UpdateAliases.WithContext(timeout),
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.StatusCode != 200 {
+		return fmt.Errorf("status=%d, msg=%s", result.StatusCode, result.String())
+	}
+
+	return nil
+}
+
+// Count implements model.Counter.
+func (s *streamer) Count(ctx context.Context, query map[string]any) (int, error) {
+	timeout, cancel := tool.TimeoutCtx(ctx, opt.Timeout)
+	defer cancel()
+
+	qs := []func(*esapi.CountRequest){
+		s.client.Count.WithContext(timeout),
+		s.client.Count.WithIndex(s.index),
+	}
+
+	if len(query) > 0 {
+		bs, err := json.Marshal(map[string]any{"query": query})
+		if err != nil {
+			return 0, err
+		}
+
+		qs = append(qs, s.client.Count.WithBody(bytes.NewReader(bs)))
+	}
+
+	r, err := s.client.Count(qs...)
+	if err != nil {
+		return 0, err
+	}
+
+	if r.StatusCode != 200 {
+		return 0, fmt.Errorf("status=%d, msg=%s", r.StatusCode, r.String())
+	}
+
+	var result struct {
+		Count int `json:"count"`
+	}
+	if err = json.NewDecoder(r.Body).Decode(&result); err != nil {
+		return 0, err
+	}
+
+	return result.Count, nil
+}
+
+// IndexStat implements model.IndexStater.
+func (s *streamer) IndexStat(ctx context.Context) (*model.IndexStat, error) {
+	timeout, cancel := tool.TimeoutCtx(ctx, opt.Timeout)
+	defer cancel()
+
+	r, err := s.client.Cat.Indices(
+		s.client.Cat.Indices.WithContext(timeout),
+		s.client.Cat.Indices.WithIndex(s.index),
+		s.client.Cat.Indices.WithFormat("json"),
+		s.client.Cat.Indices.WithBytes("b"),
+		s.client.Cat.Indices.WithH("docs.count", "pri.store.size"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.StatusCode == 404 {
+		return &model.IndexStat{}, nil
+	}
+
+	if r.StatusCode != 200 {
+		return nil, fmt.Errorf("status=%d, msg=%s", r.StatusCode, r.String())
+	}
+
+	return model.CatIndexStat(r.Body)
+}
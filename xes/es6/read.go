@@ -0,0 +1,471 @@
+package es6
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	elastic "github.com/elastic/go-elasticsearch/v6"
+	"github.com/elastic/go-elasticsearch/v6/esapi"
+	"github.com/loveuer/esgo2dump/internal/opt"
+	"github.com/loveuer/esgo2dump/internal/tool"
+	"github.com/loveuer/esgo2dump/pkg/log"
+	"github.com/loveuer/esgo2dump/pkg/model"
+	"github.com/samber/lo"
+)
+
+// DefaultDocType is used for bulk writes when neither the item nor the target index mapping carries a type
+const DefaultDocType = "_doc"
+
+type streamer struct {
+	ctx     context.Context
+	client  *elastic.Client
+	index   string
+	scroll  string
+	docType string
+	mu      sync.Mutex
+	// rename rewrites the preserved _index of docs, see --index-rename
+	rename tool.IndexRenamer
+}
+
+func (s *streamer) Cleanup() {
+	if s.scroll == "" {
+		return
+	}
+
+	defer func() { s.scroll = "" }()
+
+	res, err := s.client.ClearScroll(
+		s.client.ClearScroll.WithContext(s.ctx),
+		s.client.ClearScroll.WithScrollID(s.scroll),
+	)
+	if err != nil {
+		log.Warn("cleanup scroll failed, err = %s", err.Error())
+		return
+	}
+
+	if res.StatusCode != 200 {
+		log.Warn("cleanup scroll failed, message = %s", res.String())
+	}
+}
+
+// ReadData implements model.IO.
+func (s *streamer) ReadData(ctx context.Context, limit int, query map[string]any, fields []string, sort []string) ([]map[string]any, error) {
+	var (
+		err    error
+		qs     []func(*esapi.SearchRequest)
+		resp   *esapi.Response
+		result = new(model.ESResponseV6[map[string]any])
+	)
+
+	if limit == 0 {
+		return nil, nil
+	}
+
+	timeout, cancel := tool.TimeoutCtx(ctx)
+	defer cancel()
+
+	if s.scroll != "" {
+		bm := map[string]any{
+			"scroll":    "35s",
+			"scroll_id": s.scroll,
+		}
+
+		bs, _ := json.Marshal(bm)
+
+		if resp, err = s.client.Scroll(
+			s.client.Scroll.WithContext(timeout),
+			s.client.Scroll.WithBody(bytes.NewReader(bs)),
+		); err != nil {
+			return nil, err
+		}
+
+		goto HandleResp
+	}
+
+	qs = []func(*esapi.SearchRequest){
+		s.client.Search.WithContext(timeout),
+		s.client.Search.WithIndex(s.index),
+		s.client.Search.WithSize(limit),
+		s.client.Search.WithScroll(35 * time.Second),
+	}
+
+	if len(fields) > 0 {
+		includes, excludes := tool.SourceFields(fields)
+		qs = append(qs, s.client.Search.WithSourceIncludes(includes...), s.client.Search.WithSourceExcludes(excludes...))
+	}
+
+	if len(sort) > 0 {
+		qs = append(qs, s.client.Search.WithSort(sort...))
+	}
+
+	if len(query) > 0 {
+		queryBs, err := json.Marshal(map[string]any{"query": query})
+		if err != nil {
+			return nil, err
+		}
+
+		qs = append(qs, s.client.Search.WithBody(bytes.NewReader(queryBs)))
+	}
+
+	if resp, err = s.client.Search(qs...); err != nil {
+		return nil, err
+	}
+
+HandleResp:
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("resp status=%d, resp=%s", resp.StatusCode, resp.String())
+	}
+
+	if err = json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return nil, err
+	}
+
+	s.scroll = result.ScrollId
+
+	return lo.Slice(
+		lo.Map(
+			result.Hits.Hits,
+			func(item *model.ESSource[map[string]any], _ int) map[string]any {
+				doc := map[string]any{
+					"_id":     item.DocId,
+					"_index":  item.Index,
+					"_type":   item.Type,
+					"_source": item.Content,
+				}
+
+				if item.Routing != "" {
+					doc["_routing"] = item.Routing
+				}
+
+				return doc
+			},
+		),
+		0,
+		limit,
+	), nil
+}
+
+// WriteData implements model.IO.
+// items may be plain documents or the {_id, _type, _routing, _source} wrappers produced by ReadData,
+// wrappers keep their id, type and routing, everything else falls back to the target index type.
+// items failed on a busy cluster are retried, the ones rejected for good come back in a *model.BulkError
+func (s *streamer) WriteData(ctx context.Context, items []map[string]any) (int, error) {
+	if len(items) == 0 {
+		return 0, nil
+	}
+
+	return tool.RetryBulk(ctx, tool.Backoff{Retries: opt.Cfg.Args.BulkRetries, Base: opt.BulkBackoff, Max: opt.BulkBackoffMax}, items, s.bulk)
+}
+
+// bulk writes items once, it returns the failed ones.
+// the body is built here, the v6 bulk indexer has no per item routing
+func (s *streamer) bulk(ctx context.Context, items []map[string]any) ([]*model.BulkFailure, error) {
+	var (
+		err     error
+		bs      []byte
+		docType string
+		resp    *esapi.Response
+		buf     = make([]byte, 0, 4096)
+		result  model.BulkResponse
+	)
+
+	if docType, err = s.targetDocType(ctx); err != nil {
+		return nil, err
+	}
+
+	for _, item := range items {
+		doc := model.ToESSource(item)
+		meta := map[string]any{"_index": s.index, "_type": docType}
+
+		if doc.Type != "" {
+			meta["_type"] = doc.Type
+		}
+
+		if opt.Cfg.Args.PreserveIndex && doc.Index != "" {
+			meta["_index"] = s.rename.Rename(doc.Index)
+		}
+
+		if doc.DocId != "" {
+			meta["_id"] = doc.DocId
+		}
+
+		if doc.Routing != "" {
+			meta["routing"] = doc.Routing
+		}
+
+		if bs, err = json.Marshal(map[string]any{"index": meta}); err != nil {
+			return nil, err
+		}
+
+		buf = append(append(buf, bs...), '\n')
+
+		if bs, err = json.Marshal(doc.Content); err != nil {
+			return nil, err
+		}
+
+		buf = append(append(buf, bs...), '\n')
+	}
+
+	// a request which never got an answer or got a busy one fails every item, any other status is an error
+	failAll := func(status int, reason string) []*model.BulkFailure {
+		return lo.Map(items, func(item map[string]any, _ int) *model.BulkFailure {
+			return &model.BulkFailure{Item: item, Status: status, Reason: reason}
+		})
+	}
+
+	timeout, cancel := tool.TimeoutCtx(ctx, opt.Timeout)
+	defer cancel()
+
+	if resp, err = s.client.Bulk(bytes.NewReader(buf), s.client.Bulk.WithContext(timeout)); err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+
+		return failAll(0, err.Error()), nil
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case 200:
+	case 429, 503:
+		return failAll(resp.StatusCode, resp.String()), nil
+	default:
+		return nil, fmt.Errorf("status=%d, msg=%s", resp.StatusCode, resp.String())
+	}
+
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	failed := result.Failures(items)
+	for _, failure := range failed {
+		log.Debug("es6.writer: on failure err log, err = %s", failure.Error())
+	}
+
+	return failed, nil
+}
+
+// targetDocType resolves the single mapping type of the target index (6.x allows only one),
+// it falls back to DefaultDocType when the index does not exist yet
+func (s *streamer) targetDocType(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.docType != "" {
+		return s.docType, nil
+	}
+
+	timeout, cancel := tool.TimeoutCtx(ctx, opt.Timeout)
+	defer cancel()
+
+	r, err := s.client.Indices.GetMapping(
+		s.client.Indices.GetMapping.WithContext(timeout),
+		s.client.Indices.GetMapping.WithIndex(s.index),
+	)
+	if err != nil {
+		return "", err
+	}
+	defer r.Body.Close()
+
+	s.docType = DefaultDocType
+
+	switch r.StatusCode {
+	case 200:
+	case 404:
+		return s.docType, nil
+	default:
+		return "", fmt.Errorf("status=%d, msg=%s", r.StatusCode, r.String())
+	}
+
+	m := make(map[string]struct {
+		Mappings map[string]any `json:"mappings"`
+	})
+	if err = json.NewDecoder(r.Body).Decode(&m); err != nil {
+		return "", err
+	}
+
+	for _, idx := range m {
+		for t := range idx.Mappings {
+			s.docType = t
+		}
+	}
+
+	log.Debug("es6.writer: index = %s, doc type = %s", s.index, s.docType)
+
+	return s.docType, nil
+}
+
+func (s *streamer) ReadMapping(ctx context.Context) (map[string]any, error) {
+	timeout, cancel := tool.TimeoutCtx(ctx, opt.Timeout)
+	defer cancel()
+
+	r, err := s.client.Indices.GetMapping(
+		s.client.Indices.GetMapping.WithContext(timeout),
+		s.client.Indices.GetMapping.WithIndex(s.index),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.StatusCode != 200 {
+		return nil, fmt.Errorf("status=%d, msg=%s", r.StatusCode, r.String())
+	}
+
+	m := make(map[string]any)
+	decoder := json.NewDecoder(r.Body)
+	if err = decoder.Decode(&m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// WriteMapping implements model.IO.
+// typeless mappings (dumped from es7) are created with include_type_name=false
+func (s *streamer) WriteMapping(ctx context.Context, mapping map[string]any) error {
+	var (
+		err    error
+		bs     []byte
+		result *esapi.Response
+	)
+
+	timeout, cancel := tool.TimeoutCtx(ctx, opt.Timeout)
+	defer cancel()
+
+	for idxKey := range mapping {
+		if bs, err = json.Marshal(mapping[idxKey]); err != nil {
+			return err
+		}
+
+		qs := []func(*esapi.IndicesCreateRequest){
+			s.client.Indices.Create.WithContext(timeout),
+			s.client.Indices.Create.WithBody(bytes.NewReader(bs)),
+		}
+
+		if body, ok := mapping[idxKey].(map[string]any); ok {
+			if mappings, ok := body["mappings"].(map[string]any); ok {
+				if _, typeless := mappings["properties"]; typeless {
+					qs = append(qs, s.client.Indices.Create.WithIncludeTypeName(false))
+				}
+			}
+		}
+
+		if result, err = s.client.Indices.Create(s.index, qs...); err != nil {
+			return err
+		}
+
+		if result.StatusCode != 200 {
+			return fmt.Errorf("status=%d, msg=%s", result.StatusCode, result.String())
+		}
+	}
+
+	return nil
+}
+
+func (s *streamer) ReadSetting(ctx context.Context) (map[string]any, error) {
+	timeout, cancel := tool.TimeoutCtx(ctx, opt.Timeout)
+	defer cancel()
+
+	r, err := s.client.Indices.GetSettings(
+		s.client.Indices.GetSettings.WithContext(timeout),
+		s.client.Indices.GetSettings.WithIndex(s.index),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.StatusCode != 200 {
+		return nil, fmt.Errorf("status=%d, msg=%s", r.StatusCode, r.String())
+	}
+
+	m := make(map[string]any)
+	decoder := json.NewDecoder(r.Body)
+	if err = decoder.Decode(&m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// WriteSetting applies the updatable part of a settings envelope to s.index, the same way as es7:
+// creation-only settings are dropped, static ones need --static-settings and a close/open of the index
+func (s *streamer) WriteSetting(ctx context.Context, setting map[string]any) error {
+	skipped, err := model.ApplySettings(
+		setting,
+		opt.Cfg.Args.StaticSettings,
+		func(m map[string]any) error { return s.putSettings(ctx, m) },
+		func(op string) error { return s.indexOp(ctx, op) },
+	)
+	if skipped != nil {
+		log.Warn("skip static settings of %s: %v, use --static-settings to apply them", s.index, skipped["index"])
+	}
+
+	return err
+}
+
+func (s *streamer) putSettings(ctx context.Context, setting map[string]any) error {
+	bs, err := json.Marshal(setting)
+	if err != nil {
+		return err
+	}
+
+	timeout, cancel := tool.TimeoutCtx(ctx, opt.Timeout)
+	defer cancel()
+
+	result, err := s.client.Indices.PutSettings(
+		bytes.NewReader(bs),
+		s.client.Indices.PutSettings.WithContext(timeout),
+		s.client.Indices.PutSettings.WithIndex(s.index),
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.StatusCode != 200 {
+		return fmt.Errorf("status=%d, msg=%s", result.StatusCode, result.String())
+	}
+
+	return nil
+}
+
+// indexOp closes or opens s.index
+func (s *streamer) indexOp(ctx context.Context, op string) error {
+	timeout, cancel := tool.TimeoutCtx(ctx, opt.Timeout)
+	defer cancel()
+
+	var (
+		err    error
+		result *esapi.Response
+	)
+
+	if op == "close" {
+		result, err = s.client.Indices.Close([]string{s.index}, s.client.Indices.Close.WithContext(timeout))
+	} else {
+		result, err = s.client.Indices.Open([]string{s.index}, s.client.Indices.Open.WithContext(timeout))
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if result.StatusCode != 200 {
+		return fmt.Errorf("%s index: status=%d, msg=%s", op, result.StatusCode, result.String())
+	}
+
+	return nil
+}
+
+func NewStreamer(ctx context.Context, client *elastic.Client, index string) (model.IO[map[string]any], error) {
+	rename, err := tool.NewIndexRenamer(opt.Cfg.Args.IndexRename)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &streamer{ctx: ctx, client: client, index: index, rename: rename}
+	return s, nil
+}
@@ -0,0 +1,238 @@
+package es6
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/loveuer/esgo2dump/internal/tool"
+)
+
+// fakeES6 is a minimal stand-in for an elasticsearch 6.x node
+type fakeES6 struct {
+	mu       sync.Mutex
+	scrolls  int
+	bulk     []map[string]any
+	settings []byte
+	created  []byte
+	// auth holds the Authorization header of every request
+	auth []string
+}
+
+func (f *fakeES6) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	bs, _ := io.ReadAll(r.Body)
+	f.auth = append(f.auth, r.Header.Get("Authorization"))
+
+	hit := func(id string) map[string]any {
+		return map[string]any{"_index": "idx", "_type": "doc", "_id": id, "_source": map[string]any{"name": id}}
+	}
+
+	switch {
+	case r.URL.Path == "/":
+		_, _ = w.Write([]byte(`{"name":"node","version":{"number":"6.8.23"},"tagline":"You Know, for Search"}`))
+	case r.URL.Path == "/idx/_search":
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"_scroll_id": "scroll-1",
+			"hits":       map[string]any{"total": 3, "hits": []any{hit("1"), hit("2")}},
+		})
+	case r.URL.Path == "/_search/scroll" && r.Method == http.MethodDelete:
+		_, _ = w.Write([]byte(`{"succeeded":true}`))
+	case r.URL.Path == "/_search/scroll":
+		f.scrolls++
+		hits := []any{}
+		if f.scrolls == 1 {
+			// a custom routed doc
+			routed := hit("3")
+			routed["_routing"] = "user-3"
+			hits = append(hits, routed)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"_scroll_id": "scroll-1",
+			"hits":       map[string]any{"total": 3, "hits": hits},
+		})
+	case r.URL.Path == "/idx/_mapping":
+		_, _ = w.Write([]byte(`{"idx":{"mappings":{"doc":{"properties":{"name":{"type":"keyword"}}}}}}`))
+	case strings.HasSuffix(r.URL.Path, "/_bulk"):
+		var items []any
+		scanner := bufio.NewScanner(bytes.NewReader(bs))
+		for scanner.Scan() {
+			line := make(map[string]any)
+			_ = json.Unmarshal(scanner.Bytes(), &line)
+			f.bulk = append(f.bulk, line)
+			if _, ok := line["index"]; ok {
+				items = append(items, map[string]any{"index": map[string]any{"status": 201}})
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"errors": false, "items": items})
+	case r.URL.Path == "/idx/_settings" && r.Method == http.MethodGet:
+		_, _ = w.Write([]byte(`{"idx":{"settings":{"index":{"number_of_shards":"1","uuid":"abc","refresh_interval":"5s"}}}}`))
+	case r.URL.Path == "/idx/_settings" && r.Method == http.MethodPut:
+		f.settings = bs
+		_, _ = w.Write([]byte(`{"acknowledged":true}`))
+	case r.URL.Path == "/idx" && r.Method == http.MethodPut:
+		f.created = bs
+		_, _ = w.Write([]byte(`{"acknowledged":true}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{}`))
+	}
+}
+
+func newTestStreamer(t *testing.T) (*fakeES6, *streamer) {
+	t.Helper()
+
+	fake := &fakeES6{}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	target, _ := url.Parse(server.URL + "/idx")
+	client, err := NewClient(context.Background(), target, nil)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	sm, err := NewStreamer(context.Background(), client, "idx")
+	if err != nil {
+		t.Fatalf("NewStreamer() error = %v", err)
+	}
+
+	return fake, sm.(*streamer)
+}
+
+func TestStreamer_ReadData(t *testing.T) {
+	fake, s := newTestStreamer(t)
+
+	var all []map[string]any
+	for {
+		items, err := s.ReadData(context.Background(), 2, nil, nil, nil)
+		if err != nil {
+			t.Fatalf("ReadData() error = %v", err)
+		}
+		if len(items) == 0 {
+			break
+		}
+		all = append(all, items...)
+	}
+	s.Cleanup()
+
+	if len(all) != 3 {
+		t.Fatalf("ReadData() got %d items, want 3", len(all))
+	}
+
+	if all[0]["_type"] != "doc" || all[0]["_id"] != "1" {
+		t.Errorf("ReadData() item = %v, want _type=doc _id=1", all[0])
+	}
+
+	if _, ok := all[0]["_routing"]; ok || all[2]["_routing"] != "user-3" {
+		t.Errorf("ReadData() items = %v, want _routing on the routed doc only", all)
+	}
+
+	if fake.scrolls != 2 {
+		t.Errorf("scroll requests = %d, want 2", fake.scrolls)
+	}
+
+	if s.scroll != "" {
+		t.Errorf("Cleanup() should reset scroll id, got %s", s.scroll)
+	}
+}
+
+func TestStreamer_WriteData(t *testing.T) {
+	fake, s := newTestStreamer(t)
+
+	items := []map[string]any{
+		{"_id": "a", "_index": "other", "_type": "doc", "_routing": "user-a", "_source": map[string]any{"name": "a"}},
+		{"name": "b"},
+	}
+
+	wrote, err := s.WriteData(context.Background(), items)
+	if err != nil {
+		t.Fatalf("WriteData() error = %v", err)
+	}
+
+	if wrote != len(items) {
+		t.Errorf("WriteData() wrote %d, want %d", wrote, len(items))
+	}
+
+	if len(fake.bulk) != 4 {
+		t.Fatalf("bulk body has %d lines, want 4: %v", len(fake.bulk), fake.bulk)
+	}
+
+	// the action line carries the type and the routing of the wrapper
+	meta := fake.bulk[0]["index"].(map[string]any)
+	if want := map[string]any{"_id": "a", "_type": "doc", "_index": "idx", "routing": "user-a"}; !reflect.DeepEqual(meta, want) {
+		t.Errorf("bulk meta = %v, want %v", meta, want)
+	}
+
+	if fake.bulk[1]["name"] != "a" || fake.bulk[1]["_source"] != nil {
+		t.Errorf("bulk doc = %v, want unwrapped _source", fake.bulk[1])
+	}
+
+	meta = fake.bulk[2]["index"].(map[string]any)
+	if want := map[string]any{"_type": "doc", "_index": "idx"}; !reflect.DeepEqual(meta, want) {
+		t.Errorf("bulk meta = %v, want type resolved from target mapping", meta)
+	}
+}
+
+func TestStreamer_MappingAndSetting(t *testing.T) {
+	fake, s := newTestStreamer(t)
+
+	mapping, err := s.ReadMapping(context.Background())
+	if err != nil {
+		t.Fatalf("ReadMapping() error = %v", err)
+	}
+
+	if err = s.WriteMapping(context.Background(), mapping); err != nil {
+		t.Fatalf("WriteMapping() error = %v", err)
+	}
+
+	if !bytes.Contains(fake.created, []byte(`"doc"`)) {
+		t.Errorf("WriteMapping() body = %s, want typed mapping", fake.created)
+	}
+
+	setting, err := s.ReadSetting(context.Background())
+	if err != nil {
+		t.Fatalf("ReadSetting() error = %v", err)
+	}
+
+	if err = s.WriteSetting(context.Background(), setting); err != nil {
+		t.Fatalf("WriteSetting() error = %v", err)
+	}
+
+	// creation-only settings are not updatable
+	if string(fake.settings) != `{"index":{"refresh_interval":"5s"}}` {
+		t.Errorf("WriteSetting() body = %s, want the dynamic settings only", fake.settings)
+	}
+}
+
+func TestNewClient_Credentials(t *testing.T) {
+	fake := &fakeES6{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	target, _ := url.Parse("http://elastic:s3cret@" + server.Listener.Addr().String())
+	if _, err := NewClient(context.Background(), target, nil); err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	// es6 has no bearer token field, it goes as a header
+	if _, err := NewClient(context.Background(), target, &tool.Conn{Auth: tool.Credentials{Bearer: "t0ken"}}); err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	if want := []string{"Basic ZWxhc3RpYzpzM2NyZXQ=", "Bearer t0ken"}; strings.Join(fake.auth, ",") != strings.Join(want, ",") {
+		t.Errorf("Authorization = %v, want %v", fake.auth, want)
+	}
+}
@@ -2,13 +2,9 @@ package es7
 
 import (
 	"context"
-	"crypto/tls"
 	"fmt"
-	"net"
-	"net/http"
 	"net/url"
 	"strings"
-	"time"
 
 	elastic "github.com/elastic/go-elasticsearch/v7"
 	"github.com/elastic/go-elasticsearch/v7/esapi"
@@ -21,16 +17,14 @@ import (
 // uri example:
 //   - http://127.0.0.1:9200
 //   - https://<username>:<password>@node1.dev:9200,node2.dev:19200,node3.dev:29200
-func NewClient(ctx context.Context, uri string) (*elastic.Client, error) {
+func NewClient(ctx context.Context, uri string, conn *tool.Conn) (*elastic.Client, error) {
 	var (
-		err      error
-		username string
-		password string
-		client   *elastic.Client
-		ins      *url.URL
+		err    error
+		client *elastic.Client
+		ins    *url.URL
 	)
 
-	if ins, err = url.Parse(uri); err != nil {
+	if ins, err = tool.ParseURI(uri); err != nil {
 		return nil, err
 	}
 
@@ -41,26 +35,25 @@ func NewClient(ctx context.Context, uri string) (*elastic.Client, error) {
 		},
 	)
 
-	if ins.User != nil {
-		username = ins.User.Username()
-		password, _ = ins.User.Password()
+	creds := conn.Credentials()
+	if creds.IsZero() && ins.User != nil {
+		creds.Username = ins.User.Username()
+		creds.Password, _ = ins.User.Password()
 	}
 
 	query := ins.Query()
 
 	if client, err = elastic.NewClient(
 		elastic.Config{
-			Addresses:     endpoints,
-			Username:      username,
-			Password:      password,
-			CACert:        nil,
-			RetryOnStatus: []int{429},
-			MaxRetries:    3,
-			RetryBackoff:  nil,
-			Transport: &http.Transport{
-				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-				DialContext:     (&net.Dialer{Timeout: 10 * time.Second}).DialContext,
-			},
+			Addresses:            endpoints,
+			Username:             creds.Username,
+			Password:             creds.Password,
+			APIKey:               creds.APIKey,
+			ServiceToken:         creds.Bearer,
+			RetryOnStatus:        []int{429},
+			MaxRetries:           3,
+			RetryBackoff:         nil,
+			Transport:            conn.Transport(),
 			DiscoverNodesOnStart: lo.If(query.Get("sniff") == "true", true).Else(false),
 		},
 	); err != nil {
@@ -69,7 +62,10 @@ func NewClient(ctx context.Context, uri string) (*elastic.Client, error) {
 
 	if query.Get("ping") != "false" {
 		var res *esapi.Response
-		if res, err = client.Ping(client.Ping.WithContext(tool.TimeoutCtx(ctx, 5))); err != nil {
+		timeout, cancel := tool.TimeoutCtx(ctx, 5)
+		defer cancel()
+
+		if res, err = client.Ping(client.Ping.WithContext(timeout)); err != nil {
 			return nil, err
 		}
 
@@ -15,7 +15,10 @@ func TestNewClient(t *testing.T) {
 	
 	uri := "http://es1.dev:9200,es2.dev:9200"
 
-	c, err := NewClient(tool.Timeout(5), uri)
+	ctx, cancel := tool.Timeout(5)
+	defer cancel()
+
+	c, err := NewClient(ctx, uri, nil)
 	if err != nil {
 		t.Skipf("Skipping test - ES server not available: %v", err)
 		return
@@ -38,7 +41,10 @@ func TestNewClient_InvalidURI(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, err := NewClient(tool.Timeout(5), tt.uri)
+			ctx, cancel := tool.Timeout(5)
+			defer cancel()
+
+			_, err := NewClient(ctx, tt.uri, nil)
 			if err == nil {
 				t.Errorf("NewClient() with invalid URI should return error, got nil")
 			}
@@ -0,0 +1,183 @@
+package es7
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/loveuer/esgo2dump/internal/opt"
+	"github.com/loveuer/esgo2dump/pkg/model"
+)
+
+func TestStreamer_CreateIndex(t *testing.T) {
+	fake, s := newTestStreamer(t)
+
+	setting := map[string]any{"src": map[string]any{"settings": map[string]any{"index": map[string]any{
+		"number_of_shards": "3",
+		"uuid":             "abc",
+		"creation_date":    "1700000000000",
+		"provided_name":    "src",
+		"version":          map[string]any{"created": "7170099"},
+	}}}}
+	mapping := map[string]any{"src": map[string]any{"mappings": map[string]any{"properties": map[string]any{"id": map[string]any{"type": "keyword"}}}}}
+
+	if err := s.CreateIndex(context.Background(), setting, mapping); err != nil {
+		t.Fatalf("CreateIndex() error = %v", err)
+	}
+
+	want := map[string]any{
+		"settings": map[string]any{"index": map[string]any{"number_of_shards": "3"}},
+		"mappings": map[string]any{"properties": map[string]any{"id": map[string]any{"type": "keyword"}}},
+	}
+	if !reflect.DeepEqual(fake.created, want) {
+		t.Errorf("CreateIndex() body = %v, want %v", fake.created, want)
+	}
+
+	if err := s.CreateIndex(context.Background(), setting, mapping); !errors.Is(err, model.ErrIndexExists) {
+		t.Errorf("CreateIndex() on an existing index error = %v, want %v", err, model.ErrIndexExists)
+	}
+}
+
+func TestStreamer_TypedMapping(t *testing.T) {
+	// an es6 mapping, its properties under the single type
+	typed := func() map[string]any {
+		return map[string]any{"src": map[string]any{"mappings": map[string]any{"doc": map[string]any{
+			"properties": map[string]any{"id": map[string]any{"type": "keyword"}},
+		}}}}
+	}
+	want := map[string]any{"properties": map[string]any{"id": map[string]any{"type": "keyword"}}}
+
+	fake, s := newTestStreamer(t)
+	if err := s.CreateIndex(context.Background(), nil, typed()); err != nil {
+		t.Fatalf("CreateIndex() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(fake.created["mappings"], want) {
+		t.Errorf("CreateIndex() mappings = %v, want %v", fake.created["mappings"], want)
+	}
+
+	fake, s = newTestStreamer(t)
+	if err := s.WriteMapping(context.Background(), typed()); err != nil {
+		t.Fatalf("WriteMapping() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(fake.created["mappings"], want) {
+		t.Errorf("WriteMapping() mappings = %v, want %v", fake.created["mappings"], want)
+	}
+}
+
+func TestStreamer_WriteSetting(t *testing.T) {
+	setting := map[string]any{"src": map[string]any{"settings": map[string]any{"index": map[string]any{
+		"number_of_shards":   "3",
+		"number_of_replicas": "1",
+		"refresh_interval":   "5s",
+		"uuid":               "abc",
+		"provided_name":      "src",
+		"analysis":           map[string]any{"analyzer": map[string]any{"lower": map[string]any{"type": "custom", "tokenizer": "keyword"}}},
+	}}}}
+
+	// only creation-only and static keys, nothing dynamic is left to merge
+	staticOnly := map[string]any{"src": map[string]any{"settings": map[string]any{"index": map[string]any{
+		"number_of_shards": "3",
+		"uuid":             "abc",
+		"analysis":         map[string]any{"analyzer": map[string]any{"lower": map[string]any{"type": "custom", "tokenizer": "keyword"}}},
+	}}}}
+
+	tests := []struct {
+		name     string
+		setting  map[string]any
+		static   bool
+		ops      []string
+		settings []map[string]any
+	}{
+		{
+			name: "dynamic only",
+			ops:  []string{"settings"},
+			settings: []map[string]any{{"index": map[string]any{
+				"number_of_replicas": "1",
+				"refresh_interval":   "5s",
+			}}},
+		},
+		{
+			name:   "static through close and open",
+			static: true,
+			ops:    []string{"close", "settings", "open"},
+			settings: []map[string]any{{"index": map[string]any{
+				"number_of_replicas":                "1",
+				"refresh_interval":                  "5s",
+				"analysis.analyzer.lower.type":      "custom",
+				"analysis.analyzer.lower.tokenizer": "keyword",
+			}}},
+		},
+		{
+			name:    "static only",
+			setting: staticOnly,
+			static:  true,
+			ops:     []string{"close", "settings", "open"},
+			settings: []map[string]any{{"index": map[string]any{
+				"analysis.analyzer.lower.type":      "custom",
+				"analysis.analyzer.lower.tokenizer": "keyword",
+			}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opt.Cfg.Args.StaticSettings = tt.static
+			defer func() { opt.Cfg.Args.StaticSettings = false }()
+
+			fake, s := newTestStreamer(t)
+
+			if tt.setting == nil {
+				tt.setting = setting
+			}
+
+			if err := s.WriteSetting(context.Background(), tt.setting); err != nil {
+				t.Fatalf("WriteSetting() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(fake.ops, tt.ops) {
+				t.Errorf("WriteSetting() calls = %v, want %v", fake.ops, tt.ops)
+			}
+
+			if !reflect.DeepEqual(fake.settings, tt.settings) {
+				t.Errorf("WriteSetting() bodies = %v, want %v", fake.settings, tt.settings)
+			}
+		})
+	}
+}
+
+func TestStreamer_CountAndStat(t *testing.T) {
+	fake, s := newTestStreamer(t)
+	fake.docs = 12
+
+	query := map[string]any{"term": map[string]any{"name": "a"}}
+	count, err := s.Count(context.Background(), query)
+	if err != nil || count != 12 {
+		t.Fatalf("Count() = %d, err = %v", count, err)
+	}
+
+	if !reflect.DeepEqual(fake.searches[0], map[string]any{"query": query}) {
+		t.Errorf("Count() body = %v", fake.searches[0])
+	}
+
+	stat, err := s.IndexStat(context.Background())
+	if err != nil {
+		t.Fatalf("IndexStat() error = %v", err)
+	}
+
+	if *stat != (model.IndexStat{}) {
+		t.Errorf("IndexStat() of a missing index = %+v", *stat)
+	}
+
+	fake.created = map[string]any{}
+
+	if stat, err = s.IndexStat(context.Background()); err != nil {
+		t.Fatalf("IndexStat() error = %v", err)
+	}
+
+	if want := (model.IndexStat{Exists: true, Docs: 12, Bytes: 4096}); *stat != want {
+		t.Errorf("IndexStat() = %+v, want %+v", *stat, want)
+	}
+}
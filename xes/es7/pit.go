@@ -0,0 +1,219 @@
+package es7
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/loveuer/esgo2dump/internal/opt"
+	"github.com/loveuer/esgo2dump/internal/tool"
+	"github.com/loveuer/esgo2dump/pkg/log"
+	"github.com/loveuer/esgo2dump/pkg/model"
+	"github.com/samber/lo"
+)
+
+const pitKeepAlive = "35s"
+
+// readSearchAfter pages with search_after, inside a point in time when paginate=pit.
+// the last hit's sort values are kept in s.searchAfter, which makes a read resumable
+func (s *streamer) readSearchAfter(ctx context.Context, limit int, query map[string]any, fields []string, sort []string) ([]map[string]any, error) {
+	var (
+		err    error
+		bs     []byte
+		resp   *esapi.Response
+		result = new(model.ESResponseV7[map[string]any])
+		body   = map[string]any{
+			"size":             limit,
+			"sort":             searchAfterSort(sort, s.tiebreaker()),
+			"track_total_hits": false,
+		}
+	)
+
+	if s.paginate == opt.PaginatePIT && s.pit == "" {
+		if err = s.openPIT(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	timeout, cancel := tool.TimeoutCtx(ctx)
+	defer cancel()
+
+	qs := []func(*esapi.SearchRequest){
+		s.client.Search.WithContext(timeout),
+	}
+
+	if len(query) > 0 {
+		body["query"] = query
+	}
+
+	if len(s.searchAfter) > 0 {
+		body["search_after"] = s.searchAfter
+	}
+
+	if s.pit != "" {
+		body["pit"] = map[string]any{"id": s.pit, "keep_alive": pitKeepAlive}
+
+		if s.sliceMax > 1 {
+			body["slice"] = map[string]any{"id": s.sliceID, "max": s.sliceMax}
+		}
+	} else {
+		qs = append(qs, s.client.Search.WithIndex(s.index))
+	}
+
+	if len(fields) > 0 {
+		includes, excludes := tool.SourceFields(fields)
+		qs = append(qs, s.client.Search.WithSourceIncludes(includes...), s.client.Search.WithSourceExcludes(excludes...))
+	}
+
+	if bs, err = json.Marshal(body); err != nil {
+		return nil, err
+	}
+
+	qs = append(qs, s.client.Search.WithBody(bytes.NewReader(bs)))
+
+	if resp, err = s.client.Search(qs...); err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("resp status=%d, resp=%s", resp.StatusCode, resp.String())
+	}
+
+	if err = json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return nil, err
+	}
+
+	if s.pit != "" && result.PitId != "" {
+		s.pit = result.PitId
+	}
+
+	if n := len(result.Hits.Hits); n > 0 {
+		s.searchAfter = result.Hits.Hits[n-1].Sort
+	}
+
+	return wrapHits(result.Hits.Hits, limit), nil
+}
+
+// Position implements model.Resumer, only search_after pagination carries a resumable position
+func (s *streamer) Position() map[string]any {
+	if len(s.searchAfter) == 0 {
+		return nil
+	}
+
+	return map[string]any{"search_after": s.searchAfter, "tiebreaker": s.tiebreaker()}
+}
+
+// Resume implements model.Resumer.
+// a pit has expired long before a resume, so a new one is opened and paging continues after the saved sort values,
+// which needs a tiebreaker meaning the same in the new pit
+func (s *streamer) Resume(ctx context.Context, position map[string]any) error {
+	if s.paginate != opt.PaginatePIT && s.paginate != opt.PaginateSearchAfter {
+		return fmt.Errorf("paginate=%s is not resumable", lo.If(s.paginate == "", opt.PaginateScroll).Else(s.paginate))
+	}
+
+	searchAfter, _ := position["search_after"].([]any)
+	if len(searchAfter) == 0 {
+		return fmt.Errorf("resume position without search_after: %v", position)
+	}
+
+	// positions without tiebreaker were saved by pit readers sorting by _shard_doc, or search_after ones by _id
+	tiebreaker, _ := position["tiebreaker"].(string)
+	if tiebreaker == "" {
+		tiebreaker = lo.If(s.paginate == opt.PaginatePIT, "_shard_doc").Else("_id")
+	}
+
+	if tiebreaker != s.tiebreaker() {
+		return fmt.Errorf("resume position sorted by %s, which does not carry over to a new pit", tiebreaker)
+	}
+
+	s.searchAfter = searchAfter
+
+	return nil
+}
+
+func (s *streamer) openPIT(ctx context.Context) error {
+	timeout, cancel := tool.TimeoutCtx(ctx)
+	defer cancel()
+
+	resp, err := s.client.OpenPointInTime(
+		[]string{s.index},
+		pitKeepAlive,
+		s.client.OpenPointInTime.WithContext(timeout),
+	)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("open pit status=%d, resp=%s", resp.StatusCode, resp.String())
+	}
+
+	pit := struct {
+		Id string `json:"id"`
+	}{}
+
+	if err = json.NewDecoder(resp.Body).Decode(&pit); err != nil {
+		return err
+	}
+
+	s.pit = pit.Id
+
+	return nil
+}
+
+func (s *streamer) closePIT() {
+	defer func() { s.pit = "" }()
+
+	bs, _ := json.Marshal(map[string]any{"id": s.pit})
+
+	res, err := s.client.ClosePointInTime(
+		s.client.ClosePointInTime.WithContext(s.ctx),
+		s.client.ClosePointInTime.WithBody(bytes.NewReader(bs)),
+	)
+	if err != nil {
+		log.Warn("cleanup pit failed, err = %s", err.Error())
+		return
+	}
+
+	if res.StatusCode != 200 {
+		log.Warn("cleanup pit failed, message = %s", res.String())
+	}
+}
+
+// tiebreaker is the unique last sort field: _shard_doc inside a pit, the cheapest, but its values (shard and lucene doc)
+// only mean something in the pit which returned them. a checkpointed pit dump resumes in a new pit, so it goes by _id like search_after
+func (s *streamer) tiebreaker() string {
+	if s.paginate == opt.PaginatePIT && opt.Cfg.Args.Checkpoint == "" {
+		return "_shard_doc"
+	}
+
+	return "_id"
+}
+
+// searchAfterSort turns <field>:<direction> args into a sort body and appends the unique tiebreaker
+func searchAfterSort(sort []string, tiebreaker string) []any {
+	sorts := make([]any, 0, len(sort)+1)
+
+	for _, item := range sort {
+		field, direction, _ := strings.Cut(item, ":")
+		if field == tiebreaker {
+			tiebreaker = ""
+		}
+
+		if direction == "" {
+			sorts = append(sorts, field)
+			continue
+		}
+
+		sorts = append(sorts, map[string]any{field: map[string]any{"order": direction}})
+	}
+
+	if tiebreaker != "" {
+		sorts = append(sorts, map[string]any{tiebreaker: map[string]any{"order": "asc"}})
+	}
+
+	return sorts
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/elastic/go-elasticsearch/v7/esutil"
 	"github.com/loveuer/esgo2dump/internal/opt"
 	"github.com/loveuer/esgo2dump/pkg/log"
+	"sync"
 	"time"
 
 	elastic "github.com/elastic/go-elasticsearch/v7"
@@ -18,13 +19,32 @@ import (
 )
 
 type streamer struct {
-	ctx    context.Context
-	client *elastic.Client
-	index  string
-	scroll string
+	ctx      context.Context
+	client   *elastic.Client
+	index    string
+	paginate string
+	scroll   string
+	// rename rewrites the preserved _index of docs, see --index-rename
+	rename tool.IndexRenamer
+	// point in time id and the sort values of the last hit, see pit.go
+	pit         string
+	searchAfter []any
+	// sliced scroll, slice.max <= 1 means no slicing
+	sliceID  int
+	sliceMax int
 }
 
 func (s *streamer) Cleanup() {
+	s.searchAfter = nil
+
+	if s.pit != "" {
+		s.closePIT()
+	}
+
+	if s.scroll == "" {
+		return
+	}
+
 	defer func() { s.scroll = "" }()
 
 	bm := map[string]any{
@@ -60,6 +80,13 @@ func (s *streamer) ReadData(ctx context.Context, limit int, query map[string]any
 		return nil, nil
 	}
 
+	if s.paginate == opt.PaginatePIT || s.paginate == opt.PaginateSearchAfter {
+		return s.readSearchAfter(ctx, limit, query, fields, sort)
+	}
+
+	timeout, cancel := tool.TimeoutCtx(ctx)
+	defer cancel()
+
 	if s.scroll != "" {
 		bm := map[string]any{
 			"scroll":    "35s",
@@ -69,7 +96,7 @@ func (s *streamer) ReadData(ctx context.Context, limit int, query map[string]any
 		bs, _ := json.Marshal(bm)
 
 		if resp, err = s.client.Scroll(
-			s.client.Scroll.WithContext(tool.TimeoutCtx(s.ctx)),
+			s.client.Scroll.WithContext(timeout),
 			s.client.Scroll.WithBody(bytes.NewReader(bs)),
 		); err != nil {
 			return nil, err
@@ -79,22 +106,33 @@ func (s *streamer) ReadData(ctx context.Context, limit int, query map[string]any
 	}
 
 	qs = []func(*esapi.SearchRequest){
-		s.client.Search.WithContext(tool.TimeoutCtx(s.ctx)),
+		s.client.Search.WithContext(timeout),
 		s.client.Search.WithIndex(s.index),
 		s.client.Search.WithSize(limit),
 		s.client.Search.WithScroll(35 * time.Second),
 	}
 
 	if len(fields) > 0 {
-		qs = append(qs, s.client.Search.WithSourceIncludes(fields...))
+		includes, excludes := tool.SourceFields(fields)
+		qs = append(qs, s.client.Search.WithSourceIncludes(includes...), s.client.Search.WithSourceExcludes(excludes...))
 	}
 
 	if len(sort) > 0 {
 		qs = append(qs, s.client.Search.WithSort(sort...))
 	}
 
-	if len(query) > 0 {
-		queryBs, err := json.Marshal(map[string]any{"query": query})
+	if len(query) > 0 || s.sliceMax > 1 {
+		body := map[string]any{}
+
+		if len(query) > 0 {
+			body["query"] = query
+		}
+
+		if s.sliceMax > 1 {
+			body["slice"] = map[string]any{"id": s.sliceID, "max": s.sliceMax}
+		}
+
+		queryBs, err := json.Marshal(body)
 		if err != nil {
 			return nil, err
 		}
@@ -118,35 +156,73 @@ HandleResp:
 
 	s.scroll = result.ScrollId
 
+	return wrapHits(result.Hits.Hits, limit), nil
+}
+
+func wrapHits(hits []*model.ESSource[map[string]any], limit int) []map[string]any {
 	return lo.Slice(
 		lo.Map(
-			result.Hits.Hits,
+			hits,
 			func(item *model.ESSource[map[string]any], _ int) map[string]any {
-				return map[string]any{
+				doc := map[string]any{
 					"_id":     item.DocId,
 					"_index":  item.Index,
 					"_source": item.Content,
 				}
+
+				if item.Routing != "" {
+					doc["_routing"] = item.Routing
+				}
+
+				return doc
 			},
 		),
 		0,
 		limit,
-	), nil
+	)
+}
+
+// Slices implements model.Slicer with sliced scroll (or sliced pit), every slice keeps its own cursor
+func (s *streamer) Slices(n int) ([]model.IO[map[string]any], error) {
+	if n < 1 {
+		return nil, fmt.Errorf("invalid slices(> 0): %d", n)
+	}
+
+	if s.paginate == opt.PaginateSearchAfter {
+		return nil, fmt.Errorf("slices need paginate=%s or paginate=%s", opt.PaginateScroll, opt.PaginatePIT)
+	}
+
+	slices := make([]model.IO[map[string]any], 0, n)
+	for i := 0; i < n; i++ {
+		slices = append(slices, &streamer{ctx: s.ctx, client: s.client, index: s.index, paginate: s.paginate, sliceID: i, sliceMax: n})
+	}
+
+	return slices, nil
 }
 
 // WriteData implements model.IO.
+// items may be plain documents or the {_id, _index, _routing, _source} wrappers produced by ReadData,
+// wrappers are unwrapped and their metadata is sent with the bulk action.
+// items failed on a busy cluster are retried, the ones rejected for good come back in a *model.BulkError
 func (s *streamer) WriteData(ctx context.Context, items []map[string]any) (int, error) {
-	var (
-		err     error
-		indexer esutil.BulkIndexer
-		total   int
-	)
-
 	if len(items) == 0 {
 		return 0, nil
 	}
 
-	count := 0
+	return tool.RetryBulk(ctx, tool.Backoff{Retries: opt.Cfg.Args.BulkRetries, Base: opt.BulkBackoff, Max: opt.BulkBackoffMax}, items, s.bulk)
+}
+
+// bulk writes items once, it returns the failed ones
+func (s *streamer) bulk(ctx context.Context, items []map[string]any) ([]*model.BulkFailure, error) {
+	var (
+		err     error
+		indexer esutil.BulkIndexer
+		mu      sync.Mutex
+		flush   error
+		// the indexer reports a failed flush only to OnError, items neither written nor failed were in it
+		written  = make([]bool, len(items))
+		failures = make([]*model.BulkFailure, len(items))
+	)
 
 	if indexer, err = esutil.NewBulkIndexer(esutil.BulkIndexerConfig{
 		NumWorkers:    0,
@@ -156,6 +232,9 @@ func (s *streamer) WriteData(ctx context.Context, items []map[string]any) (int,
 		Decoder:       nil,
 		OnError: func(ctx context.Context, err error) {
 			log.Error("es7.writer: on error log, err = %s", err.Error())
+			mu.Lock()
+			flush = err
+			mu.Unlock()
 		},
 		Index:               s.index,
 		ErrorTrace:          true,
@@ -172,39 +251,69 @@ func (s *streamer) WriteData(ctx context.Context, items []map[string]any) (int,
 		Timeout:             0,
 		WaitForActiveShards: "",
 	}); err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	for _, item := range items {
-		var bs []byte
+	for i, item := range items {
+		i, item := i, item
+
+		var (
+			bs    []byte
+			doc   = model.ToESSource(item)
+			index = s.index
+		)
 
-		if bs, err = json.Marshal(item); err != nil {
-			return 0, err
+		if opt.Cfg.Args.PreserveIndex && doc.Index != "" {
+			index = s.rename.Rename(doc.Index)
+		}
+
+		if bs, err = json.Marshal(doc.Content); err != nil {
+			return nil, err
 		}
 
 		if err = indexer.Add(context.Background(), esutil.BulkIndexerItem{
-			Action: "index",
-			Index:  s.index,
-			Body:   bytes.NewReader(bs),
-			OnFailure: func(ctx context.Context, item esutil.BulkIndexerItem, item2 esutil.BulkIndexerResponseItem, bulkErr error) {
-				log.Error("es7.writer: on failure err log, err = %s", bulkErr.Error())
+			Action:     "index",
+			Index:      index,
+			DocumentID: doc.DocId,
+			Routing:    doc.Routing,
+			Body:       bytes.NewReader(bs),
+			OnSuccess: func(ctx context.Context, _ esutil.BulkIndexerItem, _ esutil.BulkIndexerResponseItem) {
+				written[i] = true
+			},
+			OnFailure: func(ctx context.Context, bi esutil.BulkIndexerItem, item2 esutil.BulkIndexerResponseItem, bulkErr error) {
+				failure := &model.BulkFailure{Item: item, Status: item2.Status, Type: item2.Error.Type, Reason: item2.Error.Reason}
+				if bulkErr != nil {
+					failure = &model.BulkFailure{Item: item, Reason: bulkErr.Error()}
+				}
+				log.Debug("es7.writer: on failure err log, id = %s, err = %s", bi.DocumentID, failure.Error())
+				failures[i] = failure
 			},
 		}); err != nil {
-			return 0, err
+			return nil, err
 		}
-
-		count++
 	}
 
-	total += count
-
 	if err = indexer.Close(ctx); err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	stats := indexer.Stats()
+	reason := "no bulk response"
+	if flush != nil {
+		reason = flush.Error()
+	}
+
+	failed := make([]*model.BulkFailure, 0)
+	for i := range items {
+		if failures[i] == nil && !written[i] {
+			failures[i] = &model.BulkFailure{Item: items[i], Reason: reason}
+		}
+
+		if failures[i] != nil {
+			failed = append(failed, failures[i])
+		}
+	}
 
-	return len(items) - int(stats.NumFailed), nil
+	return failed, nil
 }
 
 func (s *streamer) ReadMapping(ctx context.Context) (map[string]any, error) {
@@ -228,6 +337,8 @@ func (s *streamer) ReadMapping(ctx context.Context) (map[string]any, error) {
 	return m, nil
 }
 
+// WriteMapping implements model.IO.
+// typed mappings dumped from es6 are unwrapped to their single type
 func (s *streamer) WriteMapping(ctx context.Context, mapping map[string]any) error {
 	var (
 		err    error
@@ -235,14 +346,25 @@ func (s *streamer) WriteMapping(ctx context.Context, mapping map[string]any) err
 		result *esapi.Response
 	)
 
+	timeout, cancel := tool.TimeoutCtx(ctx, opt.Timeout)
+	defer cancel()
+
 	for idxKey := range mapping {
-		if bs, err = json.Marshal(mapping[idxKey]); err != nil {
+		body := mapping[idxKey]
+
+		if m, ok := body.(map[string]any); ok {
+			if mappings, ok := m["mappings"].(map[string]any); ok {
+				m["mappings"] = model.Typeless(mappings)
+			}
+		}
+
+		if bs, err = json.Marshal(body); err != nil {
 			return err
 		}
 
 		if result, err = s.client.Indices.Create(
 			s.index,
-			s.client.Indices.Create.WithContext(tool.TimeoutCtx(ctx, opt.Timeout)),
+			s.client.Indices.Create.WithContext(timeout),
 			s.client.Indices.Create.WithBody(bytes.NewReader(bs)),
 		); err != nil {
 			return err
@@ -257,8 +379,11 @@ func (s *streamer) WriteMapping(ctx context.Context, mapping map[string]any) err
 }
 
 func (s *streamer) ReadSetting(ctx context.Context) (map[string]any, error) {
+	timeout, cancel := tool.TimeoutCtx(ctx, opt.Timeout)
+	defer cancel()
+
 	r, err := s.client.Indices.GetSettings(
-		s.client.Indices.GetSettings.WithContext(tool.TimeoutCtx(ctx, opt.Timeout)),
+		s.client.Indices.GetSettings.WithContext(timeout),
 		s.client.Indices.GetSettings.WithIndex(s.index),
 	)
 	if err != nil {
@@ -278,21 +403,38 @@ func (s *streamer) ReadSetting(ctx context.Context) (map[string]any, error) {
 	return m, nil
 }
 
+// WriteSetting updates the settings of s.index with the portable settings of any index envelope.
+// settings which can only be set on creation are dropped, static ones need --static-settings
+// as they are applied by closing and reopening the index
 func (s *streamer) WriteSetting(ctx context.Context, setting map[string]any) error {
-	var (
-		err    error
-		bs     []byte
-		result *esapi.Response
+	skipped, err := model.ApplySettings(
+		setting,
+		opt.Cfg.Args.StaticSettings,
+		func(m map[string]any) error { return s.putSettings(ctx, m) },
+		func(op string) error { return s.indexOp(ctx, op) },
 	)
+	if skipped != nil {
+		log.Warn("skip static settings of %s: %v, use --static-settings to apply them", s.index, skipped["index"])
+	}
+
+	return err
+}
 
-	if bs, err = json.Marshal(setting); err != nil {
+func (s *streamer) putSettings(ctx context.Context, setting map[string]any) error {
+	bs, err := json.Marshal(setting)
+	if err != nil {
 		return err
 	}
 
-	if result, err = s.client.Indices.PutSettings(
+	timeout, cancel := tool.TimeoutCtx(ctx, opt.Timeout)
+	defer cancel()
+
+	result, err := s.client.Indices.PutSettings(
 		bytes.NewReader(bs),
-		s.client.Indices.PutSettings.WithContext(tool.TimeoutCtx(ctx, opt.Timeout)),
-	); err != nil {
+		s.client.Indices.PutSettings.WithContext(timeout),
+		s.client.Indices.PutSettings.WithIndex(s.index),
+	)
+	if err != nil {
 		return err
 	}
 
@@ -303,7 +445,39 @@ func (s *streamer) WriteSetting(ctx context.Context, setting map[string]any) err
 	return nil
 }
 
+// indexOp closes or opens s.index
+func (s *streamer) indexOp(ctx context.Context, op string) error {
+	timeout, cancel := tool.TimeoutCtx(ctx, opt.Timeout)
+	defer cancel()
+
+	var (
+		err    error
+		result *esapi.Response
+	)
+
+	if op == "close" {
+		result, err = s.client.Indices.Close([]string{s.index}, s.client.Indices.Close.WithContext(timeout))
+	} else {
+		result, err = s.client.Indices.Open([]string{s.index}, s.client.Indices.Open.WithContext(timeout))
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if result.StatusCode != 200 {
+		return fmt.Errorf("%s index: status=%d, msg=%s", op, result.StatusCode, result.String())
+	}
+
+	return nil
+}
+
 func NewStreamer(ctx context.Context, client *elastic.Client, index string) (model.IO[map[string]any], error) {
-	s := &streamer{ctx: ctx, client: client, index: index}
+	rename, err := tool.NewIndexRenamer(opt.Cfg.Args.IndexRename)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &streamer{ctx: ctx, client: client, index: index, paginate: opt.Cfg.Args.Paginate, rename: rename}
 	return s, nil
 }
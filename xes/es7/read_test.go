@@ -0,0 +1,432 @@
+package es7
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/loveuer/esgo2dump/internal/opt"
+	"github.com/loveuer/esgo2dump/internal/tool"
+	"github.com/loveuer/esgo2dump/pkg/model"
+)
+
+// fakeES7 is a minimal stand-in for an elasticsearch 7.x node
+type fakeES7 struct {
+	mu       sync.Mutex
+	docs     int
+	bulk     []map[string]any
+	searches []map[string]any
+	pits     []string
+	// created holds the create index body of idx, nil until it is created
+	created map[string]any
+	// settings holds the update settings bodies of idx, ops its close/settings/open calls in order
+	settings []map[string]any
+	ops      []string
+	// auth holds the Authorization header of every request
+	auth []string
+	// sent counts the bulk actions by _id: bad is always rejected, busy by its first action
+	sent map[string]int
+}
+
+func (f *fakeES7) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Elastic-Product", "Elasticsearch")
+	bs, _ := io.ReadAll(r.Body)
+	f.auth = append(f.auth, r.Header.Get("Authorization"))
+
+	switch {
+	case r.URL.Path == "/":
+		_, _ = w.Write([]byte(`{"name":"node","version":{"number":"7.17.10","build_flavor":"default"},"tagline":"You Know, for Search"}`))
+	case r.URL.Path == "/idx/_pit":
+		f.pits = append(f.pits, "open")
+		_, _ = w.Write([]byte(`{"id":"pit-1"}`))
+	case r.URL.Path == "/_pit" && r.Method == http.MethodDelete:
+		f.pits = append(f.pits, "close")
+		_, _ = w.Write([]byte(`{"succeeded":true}`))
+	case r.URL.Path == "/idx/_search" || r.URL.Path == "/_search":
+		body := make(map[string]any)
+		_ = json.Unmarshal(bs, &body)
+		f.searches = append(f.searches, body)
+
+		// docs are sorted by their numeric id, search_after holds the last id seen
+		after, size := 0, f.docs
+		if sa, ok := body["search_after"].([]any); ok {
+			after = int(sa[0].(float64))
+		}
+		if v, ok := body["size"].(float64); ok {
+			size = int(v)
+		}
+
+		hits := []any{}
+		for i := after + 1; i <= f.docs && len(hits) < size; i++ {
+			hits = append(hits, map[string]any{"_index": "idx", "_id": strconv.Itoa(i), "_source": map[string]any{}, "sort": []any{i}})
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]any{"pit_id": "pit-1", "hits": map[string]any{"hits": hits}})
+	case r.URL.Path == "/idx" && r.Method == http.MethodPut:
+		if f.created != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":{"type":"resource_already_exists_exception","reason":"index [idx/abc] already exists"},"status":400}`))
+			return
+		}
+
+		f.created = make(map[string]any)
+		_ = json.Unmarshal(bs, &f.created)
+		_, _ = w.Write([]byte(`{"acknowledged":true,"index":"idx"}`))
+	case r.URL.Path == "/idx/_count":
+		body := make(map[string]any)
+		_ = json.Unmarshal(bs, &body)
+		f.searches = append(f.searches, body)
+		_ = json.NewEncoder(w).Encode(map[string]any{"count": f.docs})
+	case r.URL.Path == "/_cat/indices/idx":
+		if f.created == nil {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":{"type":"index_not_found_exception"},"status":404}`))
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode([]map[string]any{{"docs.count": strconv.Itoa(f.docs), "pri.store.size": "4096"}})
+	case r.URL.Path == "/idx/_settings" && r.Method == http.MethodPut:
+		body := make(map[string]any)
+		_ = json.Unmarshal(bs, &body)
+		f.settings = append(f.settings, body)
+		f.ops = append(f.ops, "settings")
+		_, _ = w.Write([]byte(`{"acknowledged":true}`))
+	case r.URL.Path == "/idx/_close" || r.URL.Path == "/idx/_open":
+		f.ops = append(f.ops, strings.TrimPrefix(r.URL.Path, "/idx/_"))
+		_, _ = w.Write([]byte(`{"acknowledged":true}`))
+	case strings.HasSuffix(r.URL.Path, "/_bulk"):
+		var items []any
+		scanner := bufio.NewScanner(bytes.NewReader(bs))
+		for scanner.Scan() {
+			line := make(map[string]any)
+			_ = json.Unmarshal(scanner.Bytes(), &line)
+			f.bulk = append(f.bulk, line)
+			if meta, ok := line["index"].(map[string]any); ok {
+				id, _ := meta["_id"].(string)
+				f.sent[id]++
+
+				result := map[string]any{"status": 201}
+				switch {
+				case id == "bad":
+					result["status"] = 400
+					result["error"] = map[string]any{"type": "mapper_parsing_exception", "reason": "failed to parse field [age]"}
+				case id == "busy" && f.sent[id] == 1:
+					result["status"] = 429
+					result["error"] = map[string]any{"type": "es_rejected_execution_exception", "reason": "rejected execution"}
+				}
+				items = append(items, map[string]any{"index": result})
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"errors": false, "items": items})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{}`))
+	}
+}
+
+func newTestStreamer(t *testing.T) (*fakeES7, *streamer) {
+	t.Helper()
+
+	fake := &fakeES7{sent: make(map[string]int)}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	client, err := NewClient(context.Background(), server.URL, nil)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	sm, err := NewStreamer(context.Background(), client, "idx")
+	if err != nil {
+		t.Fatalf("NewStreamer() error = %v", err)
+	}
+
+	return fake, sm.(*streamer)
+}
+
+func TestStreamer_WriteData_Metadata(t *testing.T) {
+	tests := []struct {
+		name          string
+		preserveIndex bool
+		rename        []string
+		item          map[string]any
+		wantMeta      map[string]any
+	}{
+		{
+			name:     "wrapper keeps id and routing",
+			item:     map[string]any{"_id": "1", "_index": "src", "_routing": "r1", "_source": map[string]any{"name": "a"}},
+			wantMeta: map[string]any{"_id": "1", "_index": "idx", "routing": "r1"},
+		},
+		{
+			name:          "wrapper keeps source index",
+			preserveIndex: true,
+			item:          map[string]any{"_id": "1", "_index": "src", "_source": map[string]any{"name": "a"}},
+			wantMeta:      map[string]any{"_id": "1", "_index": "src"},
+		},
+		{
+			name:          "preserved index is renamed",
+			preserveIndex: true,
+			rename:        []string{"s(.*)=>staging-$1"},
+			item:          map[string]any{"_id": "1", "_index": "src", "_source": map[string]any{"name": "a"}},
+			wantMeta:      map[string]any{"_id": "1", "_index": "staging-rc"},
+		},
+		{
+			name:     "plain document",
+			item:     map[string]any{"name": "a"},
+			wantMeta: map[string]any{"_index": "idx"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opt.Cfg.Args.PreserveIndex, opt.Cfg.Args.IndexRename = tt.preserveIndex, tt.rename
+			defer func() { opt.Cfg.Args.PreserveIndex, opt.Cfg.Args.IndexRename = false, nil }()
+
+			fake, s := newTestStreamer(t)
+
+			wrote, err := s.WriteData(context.Background(), []map[string]any{tt.item})
+			if err != nil || wrote != 1 {
+				t.Fatalf("WriteData() wrote = %d, err = %v", wrote, err)
+			}
+
+			if len(fake.bulk) != 2 {
+				t.Fatalf("bulk body has %d lines, want 2", len(fake.bulk))
+			}
+
+			meta := fake.bulk[0]["index"].(map[string]any)
+			if len(meta) != len(tt.wantMeta) {
+				t.Errorf("bulk meta = %v, want %v", meta, tt.wantMeta)
+			}
+			for k, v := range tt.wantMeta {
+				if meta[k] != v {
+					t.Errorf("bulk meta[%s] = %v, want %v", k, meta[k], v)
+				}
+			}
+
+			if fake.bulk[1]["name"] != "a" || fake.bulk[1]["_source"] != nil {
+				t.Errorf("bulk doc = %v, want unwrapped source", fake.bulk[1])
+			}
+		})
+	}
+}
+
+func TestStreamer_WriteData_Retry(t *testing.T) {
+	fake, s := newTestStreamer(t)
+
+	opt.Cfg.Args.BulkRetries, opt.BulkBackoff = 2, time.Millisecond
+	defer func() { opt.Cfg.Args.BulkRetries, opt.BulkBackoff = 0, 500*time.Millisecond }()
+
+	items := []map[string]any{
+		{"_id": "a", "_source": map[string]any{"name": "a"}},
+		{"_id": "busy", "_source": map[string]any{"name": "busy"}},
+		{"_id": "bad", "_source": map[string]any{"age": "x"}},
+	}
+
+	wrote, err := s.WriteData(context.Background(), items)
+
+	var bulkErr *model.BulkError
+	if !errors.As(err, &bulkErr) || len(bulkErr.Failures) != 1 || wrote != 2 {
+		t.Fatalf("WriteData() = %d, %v, want 2 written and bad rejected", wrote, err)
+	}
+
+	if failure := bulkErr.Failures[0]; failure.Item["_id"] != "bad" || failure.Status != 400 || failure.Reason != "failed to parse field [age]" {
+		t.Errorf("WriteData() failure = %+v", failure)
+	}
+
+	// busy is sent again, the mapping error is not
+	if fake.sent["a"] != 1 || fake.sent["busy"] != 2 || fake.sent["bad"] != 1 {
+		t.Errorf("bulk actions by _id = %v", fake.sent)
+	}
+}
+
+func TestStreamer_Slices(t *testing.T) {
+	fake, s := newTestStreamer(t)
+
+	slices, err := s.Slices(3)
+	if err != nil || len(slices) != 3 {
+		t.Fatalf("Slices() got %d slices, err = %v", len(slices), err)
+	}
+
+	for _, slice := range slices {
+		if _, err = slice.ReadData(context.Background(), 10, map[string]any{"match_all": map[string]any{}}, nil, nil); err != nil {
+			t.Fatalf("ReadData() error = %v", err)
+		}
+	}
+
+	for i, body := range fake.searches {
+		slice, ok := body["slice"].(map[string]any)
+		if !ok || slice["id"] != float64(i) || slice["max"] != float64(3) {
+			t.Errorf("search[%d] slice = %v, want id=%d max=3", i, body["slice"], i)
+		}
+
+		if _, ok = body["query"]; !ok {
+			t.Errorf("search[%d] lost query: %v", i, body)
+		}
+	}
+
+	if _, err = s.Slices(0); err == nil {
+		t.Error("Slices(0) should return error")
+	}
+}
+
+func TestStreamer_ReadData_SearchAfter(t *testing.T) {
+	tests := []struct {
+		name       string
+		paginate   string
+		tiebreaker string
+		wantPits   []string
+	}{
+		{"point in time", opt.PaginatePIT, "_shard_doc", []string{"open", "close"}},
+		{"search after", opt.PaginateSearchAfter, "_id", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opt.Cfg.Args.Paginate = tt.paginate
+			defer func() { opt.Cfg.Args.Paginate = "" }()
+
+			fake, s := newTestStreamer(t)
+			fake.docs = 5
+
+			var ids []any
+			for {
+				items, err := s.ReadData(context.Background(), 2, nil, nil, []string{"created_at:desc"})
+				if err != nil {
+					t.Fatalf("ReadData() error = %v", err)
+				}
+				if len(items) == 0 {
+					break
+				}
+				for _, item := range items {
+					ids = append(ids, item["_id"])
+				}
+			}
+			s.Cleanup()
+
+			if len(ids) != 5 || ids[0] != "1" || ids[4] != "5" {
+				t.Errorf("ReadData() ids = %v, want 1..5", ids)
+			}
+
+			last := fake.searches[len(fake.searches)-1]
+			if sa, _ := last["search_after"].([]any); len(sa) != 1 || sa[0] != float64(5) {
+				t.Errorf("last search_after = %v, want [5]", last["search_after"])
+			}
+
+			sorts, _ := last["sort"].([]any)
+			if len(sorts) != 2 {
+				t.Fatalf("sort = %v, want user sort and tiebreaker", last["sort"])
+			}
+			if _, ok := sorts[1].(map[string]any)[tt.tiebreaker]; !ok {
+				t.Errorf("sort = %v, want tiebreaker %s", sorts, tt.tiebreaker)
+			}
+
+			_, hasPit := last["pit"]
+			if hasPit != (tt.paginate == opt.PaginatePIT) {
+				t.Errorf("search body pit = %v, paginate = %s", last["pit"], tt.paginate)
+			}
+
+			if strings.Join(fake.pits, ",") != strings.Join(tt.wantPits, ",") {
+				t.Errorf("pit calls = %v, want %v", fake.pits, tt.wantPits)
+			}
+
+			if s.pit != "" || s.searchAfter != nil {
+				t.Errorf("Cleanup() left pit = %q, search_after = %v", s.pit, s.searchAfter)
+			}
+		})
+	}
+}
+
+func TestStreamer_Resume_PIT(t *testing.T) {
+	opt.Cfg.Args.Paginate, opt.Cfg.Args.Checkpoint = opt.PaginatePIT, "dump.checkpoint"
+	defer func() { opt.Cfg.Args.Paginate, opt.Cfg.Args.Checkpoint = "", "" }()
+
+	fake, s := newTestStreamer(t)
+	fake.docs = 5
+
+	if _, err := s.ReadData(context.Background(), 2, nil, nil, nil); err != nil {
+		t.Fatalf("ReadData() error = %v", err)
+	}
+
+	// the interrupted run closes its pit, the resumed one opens another and goes on after doc 2
+	position := s.Position()
+	s.Cleanup()
+
+	bs, _ := json.Marshal(position)
+	saved := make(map[string]any)
+	_ = json.Unmarshal(bs, &saved)
+
+	sm, err := NewStreamer(context.Background(), s.client, "idx")
+	if err != nil {
+		t.Fatalf("NewStreamer() error = %v", err)
+	}
+
+	resumed := sm.(*streamer)
+	fake.searches, fake.pits = nil, nil
+
+	if err = resumed.Resume(context.Background(), saved); err != nil {
+		t.Fatalf("Resume() error = %v", err)
+	}
+
+	items, err := resumed.ReadData(context.Background(), 10, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("ReadData() error = %v", err)
+	}
+
+	if len(items) != 3 || items[0]["_id"] != "3" {
+		t.Errorf("resumed ReadData() = %v, want docs 3..5", items)
+	}
+
+	sorts, _ := fake.searches[0]["sort"].([]any)
+	if _, ok := sorts[len(sorts)-1].(map[string]any)["_id"]; !ok || fake.pits[0] != "open" {
+		t.Errorf("resumed search sort = %v, pit calls = %v, want _id tiebreaker in a new pit", sorts, fake.pits)
+	}
+
+	// a _shard_doc position of another pit is refused, the reader skips the dumped docs instead
+	if err = resumed.Resume(context.Background(), map[string]any{"search_after": []any{float64(2)}}); err == nil {
+		t.Error("Resume() of a _shard_doc position should fail")
+	}
+}
+
+func TestNewClient_Credentials(t *testing.T) {
+	tests := []struct {
+		name string
+		uri  string
+		conn *tool.Conn
+		want string
+	}{
+		{name: "uri user", uri: "http://elastic:s3cret@%s", want: "Basic ZWxhc3RpYzpzM2NyZXQ="},
+		{name: "api key", uri: "http://elastic:s3cret@%s", conn: &tool.Conn{Auth: tool.Credentials{APIKey: "aWQ6a2V5"}}, want: "APIKey aWQ6a2V5"},
+		{name: "bearer", uri: "http://%s", conn: &tool.Conn{Auth: tool.Credentials{Bearer: "t0ken"}}, want: "Bearer t0ken"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeES7{}
+			server := httptest.NewServer(fake)
+			defer server.Close()
+
+			if _, err := NewClient(context.Background(), fmt.Sprintf(tt.uri, server.Listener.Addr()), tt.conn); err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+
+			if len(fake.auth) == 0 || fake.auth[0] != tt.want {
+				t.Errorf("Authorization = %v, want %s", fake.auth, tt.want)
+			}
+		})
+	}
+}
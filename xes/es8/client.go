@@ -0,0 +1,138 @@
+package es8
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/loveuer/esgo2dump/internal/tool"
+	"github.com/loveuer/esgo2dump/pkg/log"
+	"github.com/samber/lo"
+)
+
+// Client
+// thin rest client for elasticsearch 8.x and opensearch,
+// the official go clients refuse to talk to any non-elastic distribution
+type Client struct {
+	endpoints []string
+	http      *resty.Client
+}
+
+// Do sends the request to the first endpoint that can be reached, body is sent as json
+func (c *Client) Do(ctx context.Context, method, path string, body []byte) (*resty.Response, error) {
+	return c.do(ctx, method, path, "application/json", body)
+}
+
+// Bulk sends a ndjson body to the _bulk api
+func (c *Client) Bulk(ctx context.Context, path string, body []byte) (*resty.Response, error) {
+	return c.do(ctx, http.MethodPost, path, "application/x-ndjson", body)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, contentType string, body []byte) (*resty.Response, error) {
+	var (
+		err error
+		rr  *resty.Response
+	)
+
+	for _, endpoint := range c.endpoints {
+		req := c.http.R().SetContext(ctx)
+		if body != nil {
+			req.SetHeader("Content-Type", contentType).SetBody(body)
+		}
+
+		if rr, err = req.Execute(method, endpoint+path); err != nil {
+			// only a node which could not be reached is passed over, a request the node may have got
+			// (a bulk of docs without _id) is not sent twice, retrying it is up to the caller
+			if ctx.Err() != nil || !isDialError(err) {
+				return nil, err
+			}
+
+			log.Debug("es8.client: %s %s%s failed, err = %s", method, endpoint, path, err.Error())
+			continue
+		}
+
+		return rr, nil
+	}
+
+	return nil, err
+}
+
+// isDialError reports whether err happened before the request was sent, like a refused connection
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// NewClient
+// new es8/opensearch client
+// uri example:
+//   - http://127.0.0.1:9200
+//   - https://<username>:<password>@node1.dev:9200,node2.dev:19200,node3.dev:29200
+//   - https://node1.dev:9200?api_key=<base64 encoded id:api_key>
+func NewClient(ctx context.Context, uri string, conn *tool.Conn) (*Client, error) {
+	var (
+		err error
+		ins *url.URL
+		rr  *resty.Response
+	)
+
+	if ins, err = tool.ParseURI(uri); err != nil {
+		return nil, err
+	}
+
+	if err = tool.ValidScheme(ins.Scheme); err != nil {
+		return nil, err
+	}
+
+	endpoints := lo.Map(
+		strings.Split(ins.Host, ","),
+		func(item string, index int) string {
+			return fmt.Sprintf("%s://%s", ins.Scheme, item)
+		},
+	)
+
+	query := ins.Query()
+
+	hc := resty.New().
+		SetTransport(conn.Transport()).
+		SetRetryCount(3).
+		AddRetryCondition(func(r *resty.Response, err error) bool {
+			return r != nil && r.StatusCode() == http.StatusTooManyRequests
+		})
+
+	creds := conn.Credentials()
+	if creds.IsZero() {
+		if ins.User != nil {
+			creds.Username = ins.User.Username()
+			creds.Password, _ = ins.User.Password()
+		}
+
+		creds.APIKey = query.Get("api_key")
+	}
+
+	if authorization := creds.Authorization(); authorization != "" {
+		hc.SetHeader("Authorization", authorization)
+	}
+
+	client := &Client{endpoints: endpoints, http: hc}
+
+	if query.Get("ping") != "false" {
+		timeout, cancel := tool.TimeoutCtx(ctx, 5)
+		defer cancel()
+
+		if rr, err = client.Do(timeout, http.MethodGet, "/", nil); err != nil {
+			return nil, err
+		}
+
+		if rr.StatusCode() != 200 {
+			return nil, fmt.Errorf("ping es server response: status=%d, msg=%s", rr.StatusCode(), rr.String())
+		}
+	}
+
+	return client, nil
+}
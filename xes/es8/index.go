@@ -0,0 +1,148 @@
+package es8
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/loveuer/esgo2dump/internal/opt"
+	"github.com/loveuer/esgo2dump/internal/tool"
+	"github.com/loveuer/esgo2dump/pkg/model"
+)
+
+// CreateIndex implements model.IndexCreator, non-portable settings are dropped, typed mappings unwrapped
+// and an existing index is model.ErrIndexExists
+func (s *streamer) CreateIndex(ctx context.Context, setting, mapping map[string]any) error {
+	var (
+		err  error
+		bs   []byte
+		rr   *resty.Response
+		body = model.IndexBody(setting, mapping)
+	)
+
+	if mappings, ok := body["mappings"].(map[string]any); ok {
+		body["mappings"] = model.Typeless(mappings)
+	}
+
+	if bs, err = json.Marshal(body); err != nil {
+		return err
+	}
+
+	timeout, cancel := tool.TimeoutCtx(ctx, opt.Timeout)
+	defer cancel()
+
+	if rr, err = s.client.Do(timeout, http.MethodPut, "/"+s.index, bs); err != nil {
+		return err
+	}
+
+	if model.IsIndexExists(rr.StatusCode(), rr.String()) {
+		return fmt.Errorf("%w: %s", model.ErrIndexExists, s.index)
+	}
+
+	if rr.StatusCode() != 200 {
+		return fmt.Errorf("status=%d, msg=%s", rr.StatusCode(), rr.String())
+	}
+
+	return nil
+}
+
+// ReadAliases implements model.Aliaser.
+func (s *streamer) ReadAliases(ctx context.Context) (map[string]any, error) {
+	return s.get(ctx, fmt.Sprintf("/%s/_alias", s.index))
+}
+
+// WriteAliases implements model.Aliaser, the aliases are added to s.index
+func (s *streamer) WriteAliases(ctx context.Context, aliases map[string]any) error {
+	actions := model.AliasActions(s.index, aliases)
+	if len(actions) == 0 {
+		return nil
+	}
+
+	bs, err := json.Marshal(map[string]any{"actions": actions})
+	if err != nil {
+		return err
+	}
+
+	return s.send(ctx, http.MethodPost, "/_aliases", bs)
+}
+
+func (s *streamer) send(ctx context.Context, method, path string, body []byte) error {
+	var (
+		err error
+		rr  *resty.Response
+	)
+
+	timeout, cancel := tool.TimeoutCtx(ctx, opt.Timeout)
+	defer cancel()
+
+	if rr, err = s.client.Do(timeout, method, path, body); err != nil {
+		return err
+	}
+
+	if rr.StatusCode() != 200 {
+		return fmt.Errorf("status=%d, msg=%s", rr.StatusCode(), rr.String())
+	}
+
+	return nil
+}
+
+// Count implements model.Counter.
+func (s *streamer) Count(ctx context.Context, query map[string]any) (int, error) {
+	var (
+		err    error
+		bs     []byte
+		rr     *resty.Response
+		result struct {
+			Count int `json:"count"`
+		}
+	)
+
+	if len(query) > 0 {
+		if bs, err = json.Marshal(map[string]any{"query": query}); err != nil {
+			return 0, err
+		}
+	}
+
+	timeout, cancel := tool.TimeoutCtx(ctx, opt.Timeout)
+	defer cancel()
+
+	if rr, err = s.client.Do(timeout, http.MethodPost, fmt.Sprintf("/%s/_count", s.index), bs); err != nil {
+		return 0, err
+	}
+
+	if rr.StatusCode() != 200 {
+		return 0, fmt.Errorf("status=%d, msg=%s", rr.StatusCode(), rr.String())
+	}
+
+	if err = json.Unmarshal(rr.Body(), &result); err != nil {
+		return 0, err
+	}
+
+	return result.Count, nil
+}
+
+// IndexStat implements model.IndexStater.
+func (s *streamer) IndexStat(ctx context.Context) (*model.IndexStat, error) {
+	path := fmt.Sprintf("/_cat/indices/%s?format=json&bytes=b&h=docs.count,pri.store.size", s.index)
+
+	timeout, cancel := tool.TimeoutCtx(ctx, opt.Timeout)
+	defer cancel()
+
+	rr, err := s.client.Do(timeout, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if rr.StatusCode() == 404 {
+		return &model.IndexStat{}, nil
+	}
+
+	if rr.StatusCode() != 200 {
+		return nil, fmt.Errorf("status=%d, msg=%s", rr.StatusCode(), rr.String())
+	}
+
+	return model.CatIndexStat(bytes.NewReader(rr.Body()))
+}
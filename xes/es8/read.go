@@ -0,0 +1,369 @@
+package es8
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/loveuer/esgo2dump/internal/opt"
+	"github.com/loveuer/esgo2dump/internal/tool"
+	"github.com/loveuer/esgo2dump/pkg/log"
+	"github.com/loveuer/esgo2dump/pkg/model"
+	"github.com/samber/lo"
+)
+
+type streamer struct {
+	ctx    context.Context
+	client *Client
+	index  string
+	scroll string
+	// rename rewrites the preserved _index of docs, see --index-rename
+	rename tool.IndexRenamer
+}
+
+func (s *streamer) Cleanup() {
+	if s.scroll == "" {
+		return
+	}
+
+	defer func() { s.scroll = "" }()
+
+	bs, _ := json.Marshal(map[string]any{"scroll_id": s.scroll})
+
+	rr, err := s.client.Do(s.ctx, http.MethodDelete, "/_search/scroll", bs)
+	if err != nil {
+		log.Warn("cleanup scroll failed, err = %s", err.Error())
+		return
+	}
+
+	if rr.StatusCode() != 200 {
+		log.Warn("cleanup scroll failed, message = %s", rr.String())
+	}
+}
+
+// ReadData implements model.IO.
+func (s *streamer) ReadData(ctx context.Context, limit int, query map[string]any, fields []string, sort []string) ([]map[string]any, error) {
+	var (
+		err    error
+		bs     []byte
+		rr     *resty.Response
+		result = new(model.ESResponseV7[map[string]any])
+	)
+
+	if limit == 0 {
+		return nil, nil
+	}
+
+	if s.scroll != "" {
+		bs, _ = json.Marshal(map[string]any{
+			"scroll":    "35s",
+			"scroll_id": s.scroll,
+		})
+
+		timeout, cancel := tool.TimeoutCtx(ctx)
+		defer cancel()
+
+		if rr, err = s.client.Do(timeout, http.MethodPost, "/_search/scroll", bs); err != nil {
+			return nil, err
+		}
+
+		goto HandleResp
+	}
+
+	{
+		params := url.Values{}
+		params.Set("scroll", "35s")
+		params.Set("size", strconv.Itoa(limit))
+
+		includes, excludes := tool.SourceFields(fields)
+		if len(includes) > 0 {
+			params.Set("_source_includes", strings.Join(includes, ","))
+		}
+
+		if len(excludes) > 0 {
+			params.Set("_source_excludes", strings.Join(excludes, ","))
+		}
+
+		if len(sort) > 0 {
+			params.Set("sort", strings.Join(sort, ","))
+		}
+
+		body := map[string]any{}
+		if len(query) > 0 {
+			body["query"] = query
+		}
+
+		if bs, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
+
+		timeout, cancel := tool.TimeoutCtx(ctx)
+		defer cancel()
+
+		if rr, err = s.client.Do(
+			timeout,
+			http.MethodPost,
+			fmt.Sprintf("/%s/_search?%s", s.index, params.Encode()),
+			bs,
+		); err != nil {
+			return nil, err
+		}
+	}
+
+HandleResp:
+
+	if rr.StatusCode() != 200 {
+		return nil, fmt.Errorf("resp status=%d, resp=%s", rr.StatusCode(), rr.String())
+	}
+
+	if err = json.Unmarshal(rr.Body(), result); err != nil {
+		return nil, err
+	}
+
+	s.scroll = result.ScrollId
+
+	return lo.Slice(
+		lo.Map(
+			result.Hits.Hits,
+			func(item *model.ESSource[map[string]any], _ int) map[string]any {
+				doc := map[string]any{
+					"_id":     item.DocId,
+					"_index":  item.Index,
+					"_source": item.Content,
+				}
+
+				if item.Routing != "" {
+					doc["_routing"] = item.Routing
+				}
+
+				return doc
+			},
+		),
+		0,
+		limit,
+	), nil
+}
+
+// WriteData implements model.IO.
+// items may be plain documents or the {_id, _index, _routing, _source} wrappers produced by ReadData,
+// any _type carried over from es6 dumps is dropped.
+// items failed on a busy cluster are retried, the ones rejected for good come back in a *model.BulkError
+func (s *streamer) WriteData(ctx context.Context, items []map[string]any) (int, error) {
+	if len(items) == 0 {
+		return 0, nil
+	}
+
+	return tool.RetryBulk(ctx, tool.Backoff{Retries: opt.Cfg.Args.BulkRetries, Base: opt.BulkBackoff, Max: opt.BulkBackoffMax}, items, s.bulk)
+}
+
+// bulk writes items once, it returns the failed ones
+func (s *streamer) bulk(ctx context.Context, items []map[string]any) ([]*model.BulkFailure, error) {
+	var (
+		err    error
+		bs     []byte
+		rr     *resty.Response
+		buf    = make([]byte, 0, 4096)
+		result model.BulkResponse
+	)
+
+	for _, item := range items {
+		doc := model.ToESSource(item)
+		meta := map[string]any{"_index": s.index}
+
+		if opt.Cfg.Args.PreserveIndex && doc.Index != "" {
+			meta["_index"] = s.rename.Rename(doc.Index)
+		}
+
+		if doc.DocId != "" {
+			meta["_id"] = doc.DocId
+		}
+
+		if doc.Routing != "" {
+			meta["routing"] = doc.Routing
+		}
+
+		if bs, err = json.Marshal(map[string]any{"index": meta}); err != nil {
+			return nil, err
+		}
+
+		buf = append(append(buf, bs...), '\n')
+
+		if bs, err = json.Marshal(doc.Content); err != nil {
+			return nil, err
+		}
+
+		buf = append(append(buf, bs...), '\n')
+	}
+
+	// a request which never got an answer or got a busy one fails every item, any other status is an error
+	failAll := func(status int, reason string) []*model.BulkFailure {
+		return lo.Map(items, func(item map[string]any, _ int) *model.BulkFailure {
+			return &model.BulkFailure{Item: item, Status: status, Reason: reason}
+		})
+	}
+
+	timeout, cancel := tool.TimeoutCtx(ctx, opt.Timeout)
+	defer cancel()
+
+	if rr, err = s.client.Bulk(timeout, "/_bulk", buf); err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+
+		return failAll(0, err.Error()), nil
+	}
+
+	switch rr.StatusCode() {
+	case 200:
+	case 429, 503:
+		return failAll(rr.StatusCode(), rr.String()), nil
+	default:
+		return nil, fmt.Errorf("status=%d, msg=%s", rr.StatusCode(), rr.String())
+	}
+
+	if err = json.Unmarshal(rr.Body(), &result); err != nil {
+		return nil, err
+	}
+
+	failed := result.Failures(items)
+	for _, failure := range failed {
+		log.Debug("es8.writer: on failure err log, err = %s", failure.Error())
+	}
+
+	return failed, nil
+}
+
+func (s *streamer) ReadMapping(ctx context.Context) (map[string]any, error) {
+	return s.get(ctx, fmt.Sprintf("/%s/_mapping", s.index))
+}
+
+// WriteMapping implements model.IO.
+// typed mappings dumped from es6 are unwrapped to their single type
+func (s *streamer) WriteMapping(ctx context.Context, mapping map[string]any) error {
+	var (
+		err error
+		bs  []byte
+		rr  *resty.Response
+	)
+
+	timeout, cancel := tool.TimeoutCtx(ctx, opt.Timeout)
+	defer cancel()
+
+	for idxKey := range mapping {
+		body := mapping[idxKey]
+
+		if m, ok := body.(map[string]any); ok {
+			if mappings, ok := m["mappings"].(map[string]any); ok {
+				m["mappings"] = model.Typeless(mappings)
+			}
+		}
+
+		if bs, err = json.Marshal(body); err != nil {
+			return err
+		}
+
+		if rr, err = s.client.Do(timeout, http.MethodPut, "/"+s.index, bs); err != nil {
+			return err
+		}
+
+		if rr.StatusCode() != 200 {
+			return fmt.Errorf("status=%d, msg=%s", rr.StatusCode(), rr.String())
+		}
+	}
+
+	return nil
+}
+
+func (s *streamer) ReadSetting(ctx context.Context) (map[string]any, error) {
+	return s.get(ctx, fmt.Sprintf("/%s/_settings", s.index))
+}
+
+// WriteSetting puts the dynamic settings of a settings envelope on s.index, creation-only ones
+// (uuid, version, number_of_shards...) are dropped. static ones are applied on a closed index with --static-settings
+func (s *streamer) WriteSetting(ctx context.Context, setting map[string]any) error {
+	skipped, err := model.ApplySettings(
+		setting,
+		opt.Cfg.Args.StaticSettings,
+		func(m map[string]any) error { return s.putSettings(ctx, m) },
+		func(op string) error { return s.indexOp(ctx, op) },
+	)
+	if skipped != nil {
+		log.Warn("skip static settings of %s: %v, use --static-settings to apply them", s.index, skipped["index"])
+	}
+
+	return err
+}
+
+func (s *streamer) putSettings(ctx context.Context, setting map[string]any) error {
+	bs, err := json.Marshal(setting)
+	if err != nil {
+		return err
+	}
+
+	timeout, cancel := tool.TimeoutCtx(ctx, opt.Timeout)
+	defer cancel()
+
+	rr, err := s.client.Do(timeout, http.MethodPut, fmt.Sprintf("/%s/_settings", s.index), bs)
+	if err != nil {
+		return err
+	}
+
+	if rr.StatusCode() != 200 {
+		return fmt.Errorf("status=%d, msg=%s", rr.StatusCode(), rr.String())
+	}
+
+	return nil
+}
+
+// indexOp closes or opens s.index
+func (s *streamer) indexOp(ctx context.Context, op string) error {
+	timeout, cancel := tool.TimeoutCtx(ctx, opt.Timeout)
+	defer cancel()
+
+	rr, err := s.client.Do(timeout, http.MethodPost, fmt.Sprintf("/%s/_%s", s.index, op), nil)
+	if err != nil {
+		return err
+	}
+
+	if rr.StatusCode() != 200 {
+		return fmt.Errorf("%s index: status=%d, msg=%s", op, rr.StatusCode(), rr.String())
+	}
+
+	return nil
+}
+
+func (s *streamer) get(ctx context.Context, path string) (map[string]any, error) {
+	timeout, cancel := tool.TimeoutCtx(ctx, opt.Timeout)
+	defer cancel()
+
+	rr, err := s.client.Do(timeout, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if rr.StatusCode() != 200 {
+		return nil, fmt.Errorf("status=%d, msg=%s", rr.StatusCode(), rr.String())
+	}
+
+	m := make(map[string]any)
+	if err = json.Unmarshal(rr.Body(), &m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func NewStreamer(ctx context.Context, client *Client, index string) (model.IO[map[string]any], error) {
+	rename, err := tool.NewIndexRenamer(opt.Cfg.Args.IndexRename)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &streamer{ctx: ctx, client: client, index: index, rename: rename}
+	return s, nil
+}
@@ -0,0 +1,344 @@
+package es8
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/loveuer/esgo2dump/internal/opt"
+	"github.com/loveuer/esgo2dump/pkg/model"
+)
+
+// fakeES8 is a minimal stand-in for an elasticsearch 8.x node
+type fakeES8 struct {
+	mu      sync.Mutex
+	auth    []string
+	scrolls int
+	bulk    []map[string]any
+	created map[string]any
+	// bulks counts the bulk requests, the doc busy is rejected by the first one and lost missing from its answer
+	bulks int
+	// settings holds the update settings bodies of idx, ops its close/settings/open calls in order
+	settings []map[string]any
+	ops      []string
+}
+
+func (f *fakeES8) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.auth = append(f.auth, r.Header.Get("Authorization"))
+	w.Header().Set("Content-Type", "application/json")
+	bs, _ := io.ReadAll(r.Body)
+
+	hit := func(id string) map[string]any {
+		return map[string]any{"_index": "idx", "_id": id, "_source": map[string]any{"name": id}}
+	}
+
+	switch {
+	case r.URL.Path == "/":
+		_, _ = w.Write([]byte(`{"name":"node","version":{"number":"8.11.1"},"tagline":"You Know, for Search"}`))
+	case r.URL.Path == "/idx/_search":
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"_scroll_id": "scroll-1",
+			"hits":       map[string]any{"total": map[string]any{"value": 3, "relation": "eq"}, "hits": []any{hit("1"), hit("2")}},
+		})
+	case r.URL.Path == "/_search/scroll" && r.Method == http.MethodDelete:
+		_, _ = w.Write([]byte(`{"succeeded":true}`))
+	case r.URL.Path == "/_search/scroll":
+		f.scrolls++
+		hits := []any{}
+		if f.scrolls == 1 {
+			hits = append(hits, hit("3"))
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"_scroll_id": "scroll-1",
+			"hits":       map[string]any{"total": map[string]any{"value": 3, "relation": "eq"}, "hits": hits},
+		})
+	case r.URL.Path == "/_bulk":
+		f.bulks++
+		var items []any
+		scanner := bufio.NewScanner(bytes.NewReader(bs))
+		for scanner.Scan() {
+			line := make(map[string]any)
+			_ = json.Unmarshal(scanner.Bytes(), &line)
+			f.bulk = append(f.bulk, line)
+			if meta, ok := line["index"].(map[string]any); ok {
+				result := map[string]any{"_id": meta["_id"], "status": 201}
+				switch {
+				case meta["_id"] == "bad":
+					result["status"] = 400
+					result["error"] = map[string]any{"type": "mapper_parsing_exception", "reason": "failed to parse field [age]"}
+				case meta["_id"] == "busy" && f.bulks == 1:
+					result["status"] = 429
+					result["error"] = map[string]any{"type": "es_rejected_execution_exception", "reason": "rejected execution"}
+				case meta["_id"] == "lost" && f.bulks == 1:
+					continue
+				}
+				items = append(items, map[string]any{"index": result})
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"errors": false, "items": items})
+	case r.URL.Path == "/idx/_settings" && r.Method == http.MethodPut:
+		body := make(map[string]any)
+		_ = json.Unmarshal(bs, &body)
+		f.settings = append(f.settings, body)
+		f.ops = append(f.ops, "settings")
+		_, _ = w.Write([]byte(`{"acknowledged":true}`))
+	case r.URL.Path == "/idx/_close" || r.URL.Path == "/idx/_open":
+		f.ops = append(f.ops, strings.TrimPrefix(r.URL.Path, "/idx/_"))
+		_, _ = w.Write([]byte(`{"acknowledged":true}`))
+	case r.URL.Path == "/idx" && r.Method == http.MethodPut:
+		_ = json.Unmarshal(bs, &f.created)
+		_, _ = w.Write([]byte(`{"acknowledged":true}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{}`))
+	}
+}
+
+func newTestStreamer(t *testing.T, query string) (*fakeES8, *streamer) {
+	t.Helper()
+
+	fake := &fakeES8{}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	client, err := NewClient(context.Background(), server.URL+"/idx"+query, nil)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	sm, err := NewStreamer(context.Background(), client, "idx")
+	if err != nil {
+		t.Fatalf("NewStreamer() error = %v", err)
+	}
+
+	return fake, sm.(*streamer)
+}
+
+func TestNewClient_APIKey(t *testing.T) {
+	fake, _ := newTestStreamer(t, "?api_key=abc123")
+
+	if len(fake.auth) != 1 || fake.auth[0] != "ApiKey abc123" {
+		t.Errorf("Authorization headers = %v, want [ApiKey abc123]", fake.auth)
+	}
+}
+
+func TestClient_Failover(t *testing.T) {
+	fake := &fakeES8{}
+	live := httptest.NewServer(fake)
+	defer live.Close()
+
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+
+	// dropping reads the request and closes the connection without an answer
+	dropping := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+		if conn, _, err := w.(http.Hijacker).Hijack(); err == nil {
+			_ = conn.Close()
+		}
+	}))
+	defer dropping.Close()
+
+	host := func(server *httptest.Server) string { return server.Listener.Addr().String() }
+
+	// a node refusing connections is passed over
+	client, err := NewClient(context.Background(), "http://"+host(dead)+","+host(live)+"/idx?ping=false", nil)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	if rr, err := client.Bulk(context.Background(), "/_bulk", []byte("{\"index\":{}}\n{}\n")); err != nil || rr.StatusCode() != 200 {
+		t.Fatalf("Bulk() after a dead node = %v, %v", rr, err)
+	}
+
+	// a node which may have got the request is not, the bulk would be written twice
+	if client, err = NewClient(context.Background(), "http://"+host(dropping)+","+host(live)+"/idx?ping=false", nil); err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	if _, err = client.Bulk(context.Background(), "/_bulk", []byte("{\"index\":{}}\n{}\n")); err == nil || fake.bulks != 1 {
+		t.Errorf("Bulk() after a dropped request err = %v, bulks = %d, want an error and no second bulk", err, fake.bulks)
+	}
+}
+
+func TestStreamer_ReadData(t *testing.T) {
+	fake, s := newTestStreamer(t, "")
+
+	var all []map[string]any
+	for {
+		items, err := s.ReadData(context.Background(), 2, map[string]any{"match_all": map[string]any{}}, nil, nil)
+		if err != nil {
+			t.Fatalf("ReadData() error = %v", err)
+		}
+		if len(items) == 0 {
+			break
+		}
+		all = append(all, items...)
+	}
+	s.Cleanup()
+
+	if len(all) != 3 {
+		t.Fatalf("ReadData() got %d items, want 3", len(all))
+	}
+
+	if _, ok := all[0]["_type"]; ok {
+		t.Errorf("ReadData() item = %v, want no _type", all[0])
+	}
+
+	if fake.scrolls != 2 || s.scroll != "" {
+		t.Errorf("scroll requests = %d, scroll id = %q, want 2 and cleared", fake.scrolls, s.scroll)
+	}
+}
+
+func TestStreamer_ReadData_Canceled(t *testing.T) {
+	_, s := newTestStreamer(t, "")
+
+	// reads follow the context of the call, not the one the streamer was made with
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := s.ReadData(ctx, 2, nil, nil, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("ReadData() on a canceled context error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestStreamer_WriteData(t *testing.T) {
+	fake, s := newTestStreamer(t, "?ping=false")
+
+	items := []map[string]any{
+		{"_id": "a", "_index": "other", "_type": "doc", "_source": map[string]any{"name": "a"}},
+		{"_id": "bad", "_source": map[string]any{"name": "bad"}},
+	}
+
+	wrote, err := s.WriteData(context.Background(), items)
+
+	var bulkErr *model.BulkError
+	if !errors.As(err, &bulkErr) || len(bulkErr.Failures) != 1 {
+		t.Fatalf("WriteData() error = %v, want the bad doc rejected", err)
+	}
+
+	if failure := bulkErr.Failures[0]; failure.Item["_id"] != "bad" || failure.Type != "mapper_parsing_exception" || failure.Retryable() {
+		t.Errorf("WriteData() failure = %+v", failure)
+	}
+
+	if wrote != 1 {
+		t.Errorf("WriteData() wrote %d, want 1", wrote)
+	}
+
+	meta := fake.bulk[0]["index"].(map[string]any)
+	if meta["_id"] != "a" || meta["_index"] != "idx" || meta["_type"] != nil {
+		t.Errorf("bulk meta = %v, want _id=a _index=idx without _type", meta)
+	}
+
+	if fake.bulk[1]["name"] != "a" {
+		t.Errorf("bulk doc = %v, want unwrapped _source", fake.bulk[1])
+	}
+}
+
+func TestStreamer_WriteData_Retry(t *testing.T) {
+	fake, s := newTestStreamer(t, "?ping=false")
+
+	opt.Cfg.Args.BulkRetries, opt.BulkBackoff = 2, time.Millisecond
+	defer func() { opt.Cfg.Args.BulkRetries, opt.BulkBackoff = 0, 500*time.Millisecond }()
+
+	items := []map[string]any{
+		{"_id": "a", "_source": map[string]any{"name": "a"}},
+		{"_id": "busy", "_source": map[string]any{"name": "busy"}},
+		{"_id": "lost", "_source": map[string]any{"name": "lost"}},
+	}
+
+	if wrote, err := s.WriteData(context.Background(), items); err != nil || wrote != 3 {
+		t.Fatalf("WriteData() = %d, %v, want 3", wrote, err)
+	}
+
+	// the retry only sends the rejected doc and the one without a response item again
+	if fake.bulks != 2 || len(fake.bulk) != 10 || fake.bulk[6]["index"].(map[string]any)["_id"] != "busy" || fake.bulk[8]["index"].(map[string]any)["_id"] != "lost" {
+		t.Errorf("bulk requests = %d, lines = %v", fake.bulks, fake.bulk)
+	}
+}
+
+func TestStreamer_WriteSetting(t *testing.T) {
+	setting := map[string]any{"src": map[string]any{"settings": map[string]any{"index": map[string]any{
+		"number_of_shards":   "3",
+		"number_of_replicas": "1",
+		"uuid":               "abc",
+		"creation_date":      "1700000000000",
+		"provided_name":      "src",
+		"version":            map[string]any{"created": "8110199"},
+		"codec":              "best_compression",
+	}}}}
+
+	tests := []struct {
+		name     string
+		static   bool
+		ops      []string
+		settings []map[string]any
+	}{
+		{
+			name:     "dynamic only",
+			ops:      []string{"settings"},
+			settings: []map[string]any{{"index": map[string]any{"number_of_replicas": "1"}}},
+		},
+		{
+			name:     "static through close and open",
+			static:   true,
+			ops:      []string{"close", "settings", "open"},
+			settings: []map[string]any{{"index": map[string]any{"number_of_replicas": "1", "codec": "best_compression"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opt.Cfg.Args.StaticSettings = tt.static
+			defer func() { opt.Cfg.Args.StaticSettings = false }()
+
+			fake, s := newTestStreamer(t, "?ping=false")
+
+			if err := s.WriteSetting(context.Background(), setting); err != nil {
+				t.Fatalf("WriteSetting() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(fake.ops, tt.ops) {
+				t.Errorf("WriteSetting() calls = %v, want %v", fake.ops, tt.ops)
+			}
+
+			if !reflect.DeepEqual(fake.settings, tt.settings) {
+				t.Errorf("WriteSetting() bodies = %v, want %v", fake.settings, tt.settings)
+			}
+		})
+	}
+}
+
+func TestStreamer_WriteMapping_Typed(t *testing.T) {
+	fake, s := newTestStreamer(t, "?ping=false")
+
+	mapping := map[string]any{
+		"idx": map[string]any{
+			"mappings": map[string]any{
+				"doc": map[string]any{"properties": map[string]any{"name": map[string]any{"type": "keyword"}}},
+			},
+		},
+	}
+
+	if err := s.WriteMapping(context.Background(), mapping); err != nil {
+		t.Fatalf("WriteMapping() error = %v", err)
+	}
+
+	mappings := fake.created["mappings"].(map[string]any)
+	if _, ok := mappings["properties"]; !ok {
+		t.Errorf("WriteMapping() mappings = %v, want typeless", mappings)
+	}
+}